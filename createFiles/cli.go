@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/davidmarkgardiner/scratchpad/createFiles/generator"
+)
+
+// Command line settings, passed to the generator as its Options.
+var (
+	// defaultTemplateVersion is the set new tenants are pinned to. Empty
+	// means the newest.
+	defaultTemplateVersion = ""
+	// environmentDirs maps operational environments onto the directory they
+	// are stored under. Test tenants share the dev tree.
+	environmentDirs = map[string]string{"test": "dev"}
+	// gitOpsRepoRoot is the root of the Git repository the delivery tool
+	// syncs from.
+	gitOpsRepoRoot = "."
+	// orphanMode is generator.OrphansRemove or generator.OrphansReport.
+	orphanMode = generator.OrphansRemove
+	// buildOutput is empty to discard built manifests, "-" for stdout, or a
+	// directory.
+	buildOutput = ""
+	// repoHostKinds classifies self-hosted Git hosts whose names give no
+	// hint, e.g. "git.internal.example.com": "gitlab".
+	repoHostKinds = map[string]string{}
+)
+
+// tenantFilter and batchOptions are filled in from flags.
+type (
+	tenantFilter = generator.TenantFilter
+	batchOptions = generator.BatchOptions
+)
+
+// newGenerator returns a generator for environmentDir using the command line
+// settings, logging to the standard logger.
+func newGenerator() (*generator.Generator, error) {
+	return generator.New(generator.Options{
+		OutputRoot:      environmentDir,
+		TemplateDir:     kustomizeDir,
+		TemplateVersion: defaultTemplateVersion,
+		EnvironmentDirs: environmentDirs,
+		GitOpsRepoRoot:  gitOpsRepoRoot,
+		OrphanMode:      orphanMode,
+		BuildOutput:     buildOutput,
+		BuildWriter:     os.Stdout,
+		RepoHostKinds:   repoHostKinds,
+		Logger:          log.Default(),
+	})
+}
+
+// generatorConfig converts the command line Config.
+func generatorConfig(config *Config) *generator.Config {
+	return &generator.Config{
+		OpEnvironment:  config.OpEnvironment,
+		Region:         config.Region,
+		ClusterName:    config.ClusterName,
+		Swci:           config.Swci,
+		Suffix:         config.Suffix,
+		FullDomainName: config.FullDomainName,
+		GitLabRepoURL:  config.GitLabRepoURL,
+	}
+}
+
+func handleDelete(config *Config) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	_, err = g.Delete(generatorConfig(config))
+	return err
+}
+
+func handlePlan(config *Config) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.WritePlan(os.Stdout, generatorConfig(config))
+}
+
+func handlePlacement(config *Config, placement string) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.Place(os.Stdout, generatorConfig(config), placement)
+}
+
+func handleRegenerate(dir string) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.Regenerate(dir)
+}
+
+func handleBatch(manifestPath string, opts batchOptions) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.Batch(os.Stdout, manifestPath, opts)
+}
+
+func handleRerender(filter tenantFilter, dryRun bool) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.Rerender(os.Stdout, filter, dryRun)
+}
+
+func handleUpgrade(filter tenantFilter, version string, dryRun bool) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.Upgrade(os.Stdout, filter, version, dryRun)
+}
+
+func handleDriftCheck(filter tenantFilter) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.DriftCheck(os.Stdout, filter)
+}
+
+func handleInventory(filter tenantFilter, sortBy, format string) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.Inventory(os.Stdout, filter, sortBy, format)
+}
+
+func handleListTemplates(version string) error {
+	g, err := newGenerator()
+	if err != nil {
+		return err
+	}
+	return g.ListTemplates(os.Stdout, version)
+}
@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"fmt"
+)
+
+const argoCDFlavourName = "argocd"
+
+// argoCDOptions are the knobs of the generated Argo CD objects.
+type argoCDOptions struct {
+	// Namespace Argo CD runs in, argocd by default.
+	Namespace string `yaml:"namespace,omitempty"`
+	// RepoURL and TargetRevision locate this repository.
+	RepoURL        string `yaml:"repoURL"`
+	TargetRevision string `yaml:"targetRevision,omitempty"`
+	// DestinationServer is the API server of the cluster, the in-cluster
+	// address by default.
+	DestinationServer string `yaml:"destinationServer,omitempty"`
+	Prune             bool   `yaml:"prune"`
+	SelfHeal          bool   `yaml:"selfHeal"`
+}
+
+type argoObjectMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type argoApplicationSource struct {
+	RepoURL        string `yaml:"repoURL"`
+	TargetRevision string `yaml:"targetRevision"`
+	Path           string `yaml:"path"`
+}
+
+type argoApplicationDestination struct {
+	Server    string `yaml:"server"`
+	Namespace string `yaml:"namespace"`
+}
+
+type argoSyncPolicyAutomated struct {
+	Prune    bool `yaml:"prune"`
+	SelfHeal bool `yaml:"selfHeal"`
+}
+
+type argoSyncPolicy struct {
+	Automated argoSyncPolicyAutomated `yaml:"automated"`
+}
+
+type argoApplicationSpec struct {
+	Project     string                     `yaml:"project"`
+	Source      argoApplicationSource      `yaml:"source"`
+	Destination argoApplicationDestination `yaml:"destination"`
+	SyncPolicy  argoSyncPolicy             `yaml:"syncPolicy"`
+}
+
+type argoApplication struct {
+	APIVersion string              `yaml:"apiVersion"`
+	Kind       string              `yaml:"kind"`
+	Metadata   argoObjectMeta      `yaml:"metadata"`
+	Spec       argoApplicationSpec `yaml:"spec"`
+}
+
+type argoGroupKind struct {
+	Group string `yaml:"group"`
+	Kind  string `yaml:"kind"`
+}
+
+type argoAppProjectSpec struct {
+	Description              string                       `yaml:"description"`
+	SourceRepos              []string                     `yaml:"sourceRepos"`
+	Destinations             []argoApplicationDestination `yaml:"destinations"`
+	ClusterResourceWhitelist []argoGroupKind              `yaml:"clusterResourceWhitelist"`
+}
+
+type argoAppProject struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   argoObjectMeta     `yaml:"metadata"`
+	Spec       argoAppProjectSpec `yaml:"spec"`
+}
+
+// argoCDFlavour delivers tenants with an Argo CD Application confined to a
+// per-tenant AppProject.
+type argoCDFlavour struct {
+	opts      *argoCDOptions
+	hostKinds map[string]string
+}
+
+func (a *argoCDFlavour) Name() string { return argoCDFlavourName }
+
+// Render builds the AppProject and Application for a tenant. The project only
+// allows the tenant namespace as destination and this repository, plus the
+// tenant's own repository if it has one, as sources.
+func (a *argoCDFlavour) Render(config *Config, path string) ([]byte, error) {
+	opts := a.opts
+	if opts.RepoURL == "" {
+		return nil, fmt.Errorf("argocd settings: repoURL is required")
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "argocd"
+	}
+	revision := opts.TargetRevision
+	if revision == "" {
+		revision = "HEAD"
+	}
+	server := opts.DestinationServer
+	if server == "" {
+		server = "https://kubernetes.default.svc"
+	}
+
+	name := tenantNamespace(config)
+	destination := argoApplicationDestination{Server: server, Namespace: name}
+	sources := []string{opts.RepoURL}
+	if config.GitLabRepoURL != "" {
+		sources = append(sources, repoSourceFor(config, a.hostKinds).CloneURL)
+	}
+
+	project := &argoAppProject{
+		APIVersion: "argoproj.io/v1alpha1",
+		Kind:       "AppProject",
+		Metadata:   argoObjectMeta{Name: name, Namespace: namespace},
+		Spec: argoAppProjectSpec{
+			Description:              fmt.Sprintf("Tenant %s on %s", name, config.ClusterName),
+			SourceRepos:              sources,
+			Destinations:             []argoApplicationDestination{destination},
+			ClusterResourceWhitelist: []argoGroupKind{{Group: "", Kind: "Namespace"}},
+		},
+	}
+	app := &argoApplication{
+		APIVersion: "argoproj.io/v1alpha1",
+		Kind:       "Application",
+		Metadata:   argoObjectMeta{Name: name, Namespace: namespace},
+		Spec: argoApplicationSpec{
+			Project:     name,
+			Source:      argoApplicationSource{RepoURL: opts.RepoURL, TargetRevision: revision, Path: path},
+			Destination: destination,
+			SyncPolicy:  argoSyncPolicy{Automated: argoSyncPolicyAutomated{Prune: opts.Prune, SelfHeal: opts.SelfHeal}},
+		},
+	}
+	return encodeYAMLStream(project, app)
+}
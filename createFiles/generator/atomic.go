@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// stageTenantDir creates a staging directory next to dir, seeded with a copy
+// of dir's current contents. Keeping it on the same filesystem lets
+// swapTenantDir move it into place with a rename.
+func (g *Generator) stageTenantDir(dir string) (string, error) {
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %v", parent, err)
+	}
+	stage, err := os.MkdirTemp(parent, "."+filepath.Base(dir)+".staging-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %v", err)
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		if err := g.copyTree(dir, stage); err != nil {
+			os.RemoveAll(stage)
+			return "", fmt.Errorf("failed to copy %s to staging: %v", dir, err)
+		}
+	} else if !os.IsNotExist(err) {
+		os.RemoveAll(stage)
+		return "", fmt.Errorf("failed to stat %s: %v", dir, err)
+	}
+	return stage, nil
+}
+
+// swapTenantDir replaces dir with stage. The previous directory is kept as a
+// backup until the new one is in place and restored if the swap fails, so
+// dir always holds either the old or the complete new contents.
+func (g *Generator) swapTenantDir(stage, dir string) error {
+	backup := ""
+	if _, err := os.Stat(dir); err == nil {
+		backup = filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".backup-"+filepath.Base(stage))
+		if err := os.Rename(dir, backup); err != nil {
+			return fmt.Errorf("failed to move %s aside: %v", dir, err)
+		}
+	}
+
+	if err := os.Rename(stage, dir); err != nil {
+		if backup != "" {
+			if restoreErr := os.Rename(backup, dir); restoreErr != nil {
+				return fmt.Errorf("failed to move staging into %s: %v (restore from %s also failed: %v)", dir, err, backup, restoreErr)
+			}
+			g.logger.Printf("Restored previous contents of %s", dir)
+		}
+		return fmt.Errorf("failed to move staging into %s: %v", dir, err)
+	}
+
+	if backup != "" {
+		if err := os.RemoveAll(backup); err != nil {
+			g.logger.Printf("Warning: failed to remove backup %s: %v", backup, err)
+		}
+	}
+	// MkdirTemp creates 0700 directories
+	return os.Chmod(dir, 0755)
+}
+
+// copyTree copies the regular files and directories below src into dst. It
+// fails on symlinks and other special files: the swap would drop them, and
+// rendering through a symlink would write outside the tenant directory.
+func (g *Generator) copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, os.ModePerm)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		default:
+			return fmt.Errorf("%s is a %s, not a regular file; move or remove it first", path, fileKind(d.Type()))
+		}
+	})
+}
+
+// fileKind names the type of a non-regular file for error messages.
+func fileKind(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	}
+	return "special file"
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,31 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSymlinkInTenantDirStopsTheSwap(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	if err := g.AddOrModify(config); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(g.tenantDir(config), "shared.yaml")
+	if err := os.Symlink(filepath.Join(t.TempDir(), "shared.yaml"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.AddOrModify(config)
+	if err == nil || !strings.Contains(err.Error(), "is a symlink") {
+		t.Fatalf("AddOrModify() error = %v, want a symlink error", err)
+	}
+	if _, err := os.Lstat(link); err != nil {
+		t.Errorf("symlink was removed: %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package generator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BatchOptions controls how a tenant manifest is processed.
+type BatchOptions struct {
+	// Parallelism is the number of tenants rendered at once. Values below
+	// one are treated as one.
+	Parallelism int
+	// StopOnError stops starting new tenants after the first failure.
+	StopOnError bool
+}
+
+// Batch result statuses.
+const (
+	batchSucceeded = "ok"
+	batchFailed    = "failed"
+	batchSkipped   = "skipped"
+)
+
+// batchTenant is one tenant of a manifest. The replicas of a placed entry
+// share Entry and are rendered on one template set, as Place renders them.
+type batchTenant struct {
+	Config Config
+	// Entry is the manifest entry the tenant comes from, counted from one.
+	Entry int
+	// Placed is set for the replicas of an entry with a placement.
+	Placed bool
+	// version is the template set of a placed replica, set by validateBatch.
+	version string
+}
+
+// batchResult is the outcome for one manifest entry.
+type batchResult struct {
+	Index     int
+	Namespace string
+	Dir       string
+	Status    string
+	Err       error
+}
+
+// loadTenantManifest reads a YAML or CSV manifest of tenant Configs. Keys and
+// CSV headers are matched case-insensitively against Config field names. An
+// entry with a placement is expanded into one tenant per placed cluster, see
+// resolvePlacement.
+func (g *Generator) loadTenantManifest(path string) ([]batchTenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %v", path, err)
+	}
+
+	var rows []map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = parseCSVManifest(data)
+	case ".yaml", ".yml":
+		rows, err = parseYAMLManifest(data)
+	default:
+		return nil, fmt.Errorf("manifest %s: unsupported format, use .yaml, .yml or .csv", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+
+	tenants := make([]batchTenant, 0, len(rows))
+	for i, row := range rows {
+		var config Config
+		placement := ""
+		for key, value := range row {
+			if strings.EqualFold(key, "placement") {
+				placement = value
+				continue
+			}
+			if err := setConfigField(&config, key, value); err != nil {
+				return nil, fmt.Errorf("manifest %s: tenant %d: %v", path, i+1, err)
+			}
+		}
+		if placement == "" {
+			tenants = append(tenants, batchTenant{Config: config, Entry: i + 1})
+			continue
+		}
+		targets, err := g.resolvePlacement(&config, placement)
+		if err != nil {
+			return nil, fmt.Errorf("manifest %s: tenant %d: %v", path, i+1, err)
+		}
+		for _, placed := range placedConfigs(&config, targets) {
+			tenants = append(tenants, batchTenant{Config: placed, Entry: i + 1, Placed: true})
+		}
+	}
+	return tenants, nil
+}
+
+// parseYAMLManifest accepts either a top-level list or a "tenants" list.
+func parseYAMLManifest(data []byte) ([]map[string]string, error) {
+	var wrapped struct {
+		Tenants []map[string]string `yaml:"tenants"`
+	}
+	if err := yaml.Unmarshal(data, &wrapped); err == nil && wrapped.Tenants != nil {
+		return wrapped.Tenants, nil
+	}
+	var rows []map[string]string
+	if err := yaml.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// parseCSVManifest reads a CSV file whose first row names the Config fields.
+func parseCSVManifest(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header row: %v", err)
+	}
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// setConfigField assigns value to the string field of config whose name
+// matches key case-insensitively.
+func setConfigField(config *Config, key, value string) error {
+	v := reflect.ValueOf(config).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Name, key) && t.Field(i).Type.Kind() == reflect.String {
+			v.Field(i).SetString(value)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown Config field %q", key)
+}
+
+// validateBatch validates every tenant and rejects manifests that would write
+// the same tenant directory twice, which also makes parallel rendering safe.
+// The replicas of a placed entry are then pinned to the template set
+// placementVersion picks for them. It returns one result per tenant and the
+// number of invalid ones; valid tenants are reported as skipped.
+func (g *Generator) validateBatch(tenants []batchTenant) ([]batchResult, int) {
+	results := make([]batchResult, len(tenants))
+	invalid := 0
+	seen := make(map[string]int)
+	for i := range tenants {
+		config := &tenants[i].Config
+		result := batchResult{Index: i + 1, Namespace: tenantNamespace(config), Status: batchSkipped}
+		if err := g.validateTarget(config); err != nil {
+			result.Status = batchFailed
+			result.Err = err
+		} else {
+			result.Dir = g.tenantDir(config)
+			if first, ok := seen[result.Dir]; ok {
+				result.Status = batchFailed
+				result.Err = fmt.Errorf("duplicate of tenant %d", first)
+			} else {
+				seen[result.Dir] = i + 1
+			}
+		}
+		if result.Status == batchFailed {
+			invalid++
+		}
+		results[i] = result
+	}
+	if invalid > 0 {
+		return results, invalid
+	}
+
+	replicas := make(map[int][]int)
+	var entries []int
+	for i := range tenants {
+		if !tenants[i].Placed {
+			continue
+		}
+		entry := tenants[i].Entry
+		if replicas[entry] == nil {
+			entries = append(entries, entry)
+		}
+		replicas[entry] = append(replicas[entry], i)
+	}
+	for _, entry := range entries {
+		configs := make([]Config, len(replicas[entry]))
+		for j, i := range replicas[entry] {
+			configs[j] = tenants[i].Config
+		}
+		version, err := g.placementVersion(configs)
+		for _, i := range replicas[entry] {
+			if err != nil {
+				results[i].Status = batchFailed
+				results[i].Err = err
+				invalid++
+				continue
+			}
+			tenants[i].version = version
+		}
+	}
+	return results, invalid
+}
+
+// runBatch generates every tenant using a worker pool and returns one result
+// per tenant in manifest order. Placed replicas are rendered on the template
+// set validateBatch pinned them to, other tenants go through AddOrModify.
+func (g *Generator) runBatch(tenants []batchTenant, opts BatchOptions) []batchResult {
+	workers := max(opts.Parallelism, 1)
+	results := make([]batchResult, len(tenants))
+	jobs := make(chan int)
+	var stopped atomic.Bool
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				tenant := &tenants[i]
+				config := &tenant.Config
+				result := batchResult{Index: i + 1, Namespace: tenantNamespace(config), Dir: g.tenantDir(config)}
+				if opts.StopOnError && stopped.Load() {
+					result.Status = batchSkipped
+					results[i] = result
+					continue
+				}
+				var err error
+				if tenant.Placed {
+					err = g.generateTenant(config, tenant.version)
+				} else {
+					err = g.AddOrModify(config)
+				}
+				if err != nil {
+					result.Status = batchFailed
+					result.Err = err
+					stopped.Store(true)
+				} else {
+					result.Status = batchSucceeded
+				}
+				results[i] = result
+			}
+		}()
+	}
+	for i := range tenants {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// printBatchReport writes one line per tenant and a summary.
+func printBatchReport(w io.Writer, results []batchResult) {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		line := fmt.Sprintf("%4d  %-8s %s", r.Index, r.Status, r.Namespace)
+		if r.Dir != "" {
+			line += "  " + r.Dir
+		}
+		if r.Err != nil {
+			line += "  " + r.Err.Error()
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "Batch summary: %d tenants, %d succeeded, %d failed, %d skipped\n",
+		len(results), counts[batchSucceeded], counts[batchFailed], counts[batchSkipped])
+}
+
+// Batch onboards every tenant in a manifest and writes a report to w. All
+// entries are validated before any tenant is written; if one is invalid
+// nothing is generated.
+func (g *Generator) Batch(w io.Writer, manifestPath string, opts BatchOptions) error {
+	tenants, err := g.loadTenantManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	g.logger.Printf("Loaded %d tenants from %s", len(tenants), manifestPath)
+
+	if results, invalid := g.validateBatch(tenants); invalid > 0 {
+		printBatchReport(w, results)
+		return fmt.Errorf("%d of %d tenants in %s are invalid, nothing was generated", invalid, len(tenants), manifestPath)
+	}
+
+	results := g.runBatch(tenants, opts)
+	printBatchReport(w, results)
+
+	for _, r := range results {
+		if r.Status != batchSucceeded {
+			return fmt.Errorf("batch %s did not complete for every tenant", manifestPath)
+		}
+	}
+	return nil
+}
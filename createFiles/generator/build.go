@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// buildTenant runs kustomize build in-process on dir and returns the
+// resulting manifest stream.
+func (g *Generator) buildTenant(dir string) ([]byte, error) {
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := kustomizer.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build %s failed: %v", dir, err)
+	}
+	g.logger.Printf("kustomize build %s produced %d resources", dir, resMap.Size())
+
+	manifests, err := resMap.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode kustomize build output for %s: %v", dir, err)
+	}
+	return manifests, nil
+}
+
+// buildOutputName names the build output file of config. It carries the
+// environment, region and cluster, so the replicas of a placed tenant, which
+// share a namespace, do not overwrite each other.
+func buildOutputName(config *Config) string {
+	return fmt.Sprintf("%s_%s_%s_%s.yaml", config.OpEnvironment, config.Region, config.ClusterName, tenantNamespace(config))
+}
+
+// verifyTenantBuild fails if the tenant rendered into buildDir does not build
+// and emits the built manifests according to BuildOutput.
+func (g *Generator) verifyTenantBuild(config *Config, buildDir string) error {
+	manifests, err := g.buildTenant(buildDir)
+	if err != nil {
+		return err
+	}
+
+	switch g.opts.BuildOutput {
+	case "":
+	case "-":
+		g.buildWriterMu.Lock()
+		_, err := fmt.Fprintf(g.opts.BuildWriter, "# Source: %s\n%s", g.tenantDir(config), manifests)
+		g.buildWriterMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to write build output: %v", err)
+		}
+	default:
+		if err := os.MkdirAll(g.opts.BuildOutput, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create build output directory %s: %v", g.opts.BuildOutput, err)
+		}
+		path := filepath.Join(g.opts.BuildOutput, buildOutputName(config))
+		if err := os.WriteFile(path, manifests, 0644); err != nil {
+			return fmt.Errorf("failed to write build output %s: %v", path, err)
+		}
+		g.logger.Printf("Wrote built manifests to %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildOutputKeepsEveryReplica(t *testing.T) {
+	out := t.TempDir()
+	g, err := New(Options{OutputRoot: t.TempDir(), BuildOutput: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "dev", Swci: "ab12", Suffix: "web"}
+	targets := []placementTarget{{Region: "uksouth", Cluster: "aks1"}, {Region: "ukwest", Cluster: "aks2"}}
+	for _, replica := range placedConfigs(config, targets) {
+		if err := g.AddOrModify(&replica); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"dev_uksouth_aks1_ab12-dev-web.yaml", "dev_ukwest_aks2_ab12-dev-web.yaml"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("build output of a replica is missing: %v", err)
+		}
+	}
+}
+
+func TestBuildOutputToWriter(t *testing.T) {
+	if _, err := New(Options{OutputRoot: t.TempDir(), BuildOutput: "-"}); err == nil {
+		t.Error(`New() accepted BuildOutput "-" without a BuildWriter`)
+	}
+
+	var out strings.Builder
+	g, err := New(Options{OutputRoot: t.TempDir(), BuildOutput: "-", BuildWriter: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	if err := g.AddOrModify(config); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "# Source: "+g.tenantDir(config)+"\n") || !strings.Contains(out.String(), "kind: Namespace") {
+		t.Errorf("BuildWriter got %q, want the built manifests of %s", out.String(), g.tenantDir(config))
+	}
+}
@@ -0,0 +1,304 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clusterCatalogFile, looked up in OutputRoot, lists the clusters tenants
+// may be placed on and what each of them supports, and so the regions tenants
+// may be created in. Without it any Region in allowedRegions and any
+// ClusterName is accepted.
+const clusterCatalogFile = "clusters.yaml"
+
+// catalogCNIs are the network plugins a catalog cluster may declare.
+var catalogCNIs = []string{"azure", "azure-overlay", "azure-cilium", "kubenet", "none"}
+
+// clusterCapacity bounds what a cluster takes on.
+type clusterCapacity struct {
+	// MaxTenants is the number of tenant namespaces the cluster may hold.
+	// Zero means no limit.
+	MaxTenants int `yaml:"maxTenants,omitempty"`
+}
+
+// catalogCluster is one cluster in the catalog, available to templates as
+// .Cluster.
+type catalogCluster struct {
+	Name   string `yaml:"name"`
+	Region string `yaml:"region"`
+	// Environments are the OpEnvironments the cluster hosts.
+	Environments  []string `yaml:"environments"`
+	IstioRevision string   `yaml:"istioRevision,omitempty"`
+	// Routing is "istio" or "gatewayapi" and Gateway the shared gateway
+	// tenant routes attach to. Empty fields fall back to the routing
+	// settings.
+	Routing string     `yaml:"routing,omitempty"`
+	Gateway gatewayRef `yaml:"gateway,omitempty"`
+	CNI     string     `yaml:"cni,omitempty"`
+	// Spot is true when the cluster has spot node pools.
+	Spot bool `yaml:"spot,omitempty"`
+	// GitOps is the delivery flavour, overriding the GitOps settings.
+	GitOps   string          `yaml:"gitops,omitempty"`
+	Capacity clusterCapacity `yaml:"capacity,omitempty"`
+}
+
+// clusterCatalog is the parsed clusterCatalogFile.
+type clusterCatalog struct {
+	Clusters []catalogCluster `yaml:"clusters"`
+	// RegionPairs groups catalog regions that back each other up, for
+	// placement selectors such as "regionPair=uk".
+	RegionPairs map[string][]string `yaml:"regionPairs,omitempty"`
+}
+
+// loadClusterCatalog reads and validates the catalog in dir. A missing file
+// yields nil.
+func (g *Generator) loadClusterCatalog(dir string) (*clusterCatalog, error) {
+	path := filepath.Join(dir, clusterCatalogFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		g.logger.Printf("No %s found, accepting any cluster in the allowed regions", path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	catalog := &clusterCatalog{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(catalog.Clusters) == 0 {
+		return nil, fmt.Errorf("%s: no clusters", path)
+	}
+
+	seen := make(map[string]bool)
+	for i := range catalog.Clusters {
+		cluster := &catalog.Clusters[i]
+		key := cluster.Region + "/" + cluster.Name
+		if err := cluster.check(); err != nil {
+			return nil, fmt.Errorf("%s: cluster %s: %v", path, key, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s: cluster %s is listed twice", path, key)
+		}
+		seen[key] = true
+	}
+	if err := catalog.checkRegionPairs(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	g.logger.Printf("Loaded %d clusters from %s", len(catalog.Clusters), path)
+	return catalog, nil
+}
+
+// check validates one catalog entry.
+func (c *catalogCluster) check() error {
+	var errs ValidationErrors
+	checkLabel(&errs, "name", c.Name)
+	checkLabel(&errs, "region", c.Region)
+	if len(c.Environments) == 0 {
+		errs.add("environments", "", CodeRequired, "is required")
+	}
+	for _, env := range c.Environments {
+		checkAllowed(&errs, "environments", env, allowedEnvironments)
+	}
+	if c.IstioRevision != "" {
+		checkLabel(&errs, "istioRevision", c.IstioRevision)
+	}
+	if c.Routing != "" && c.Routing != routingIstio && c.Routing != routingGatewayAPI {
+		errs.add("routing", c.Routing, CodeNotAllowed, "must be one of %s, %s", routingIstio, routingGatewayAPI)
+	}
+	if (c.Gateway.Name == "") != (c.Gateway.Namespace == "") {
+		errs.add("gateway", c.Gateway.String(), CodeInvalid, "needs both name and namespace")
+	}
+	if c.CNI != "" {
+		checkAllowed(&errs, "cni", c.CNI, catalogCNIs)
+	}
+	if c.GitOps != "" {
+		checkAllowed(&errs, "gitops", c.GitOps, append([]string{gitOpsNone}, gitOpsFlavourNames...))
+	}
+	if c.Capacity.MaxTenants < 0 {
+		errs.add("capacity.maxTenants", fmt.Sprint(c.Capacity.MaxTenants), CodeInvalid, "must not be negative")
+	}
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, fe := range errs {
+			msgs[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+		}
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// checkRegionPairs requires every pair to name catalog regions and every
+// region to belong to one pair at most.
+func (c *clusterCatalog) checkRegionPairs() error {
+	regions := make(map[string]bool)
+	for _, cluster := range c.Clusters {
+		regions[cluster.Region] = true
+	}
+	names := make([]string, 0, len(c.RegionPairs))
+	for name := range c.RegionPairs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairOf := make(map[string]string)
+	for _, name := range names {
+		var errs ValidationErrors
+		checkLabel(&errs, "name", name)
+		if len(errs) > 0 {
+			return fmt.Errorf("region pair %q: %s", name, errs[0].Message)
+		}
+		if len(c.RegionPairs[name]) == 0 {
+			return fmt.Errorf("region pair %s: no regions", name)
+		}
+		for _, region := range c.RegionPairs[name] {
+			if !regions[region] {
+				return fmt.Errorf("region pair %s: no cluster in region %s", name, region)
+			}
+			if other, ok := pairOf[region]; ok {
+				return fmt.Errorf("region pair %s: region %s is already in pair %s", name, region, other)
+			}
+			pairOf[region] = name
+		}
+	}
+	return nil
+}
+
+// regionPairOf returns the name of the pair region belongs to, or "".
+func (c *clusterCatalog) regionPairOf(region string) string {
+	for pair, regions := range c.RegionPairs {
+		for _, r := range regions {
+			if r == region {
+				return pair
+			}
+		}
+	}
+	return ""
+}
+
+// allowedTenantRegions returns the regions tenants may be created in: those of
+// the catalog clusters when there is a catalog, allowedRegions otherwise.
+func (g *Generator) allowedTenantRegions() ([]string, error) {
+	catalog, err := g.currentClusterCatalog()
+	if err != nil || catalog == nil {
+		return allowedRegions, err
+	}
+	seen := make(map[string]bool)
+	var regions []string
+	for _, cluster := range catalog.Clusters {
+		if !seen[cluster.Region] {
+			seen[cluster.Region] = true
+			regions = append(regions, cluster.Region)
+		}
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// currentClusterCatalog loads the catalog once per Generator. It returns nil
+// when there is no catalog.
+func (g *Generator) currentClusterCatalog() (*clusterCatalog, error) {
+	g.clusterCatalogOnce.Do(func() {
+		g.catalogLoaded, g.catalogLoadErr = g.loadClusterCatalog(g.opts.OutputRoot)
+	})
+	return g.catalogLoaded, g.catalogLoadErr
+}
+
+// lookup returns the cluster named name in region, or nil.
+func (c *clusterCatalog) lookup(region, name string) *catalogCluster {
+	for i := range c.Clusters {
+		if c.Clusters[i].Region == region && c.Clusters[i].Name == name {
+			return &c.Clusters[i]
+		}
+	}
+	return nil
+}
+
+// hosts reports whether the cluster hosts tenants of environment env.
+func (c *catalogCluster) hosts(env string) bool {
+	for _, e := range c.Environments {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterFor returns the catalog entry of config's cluster. Without a catalog
+// it returns an empty entry, so templates can use .Cluster either way.
+func (g *Generator) clusterFor(config *Config) (*catalogCluster, error) {
+	catalog, err := g.currentClusterCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if catalog == nil {
+		return &catalogCluster{}, nil
+	}
+	cluster := catalog.lookup(config.Region, config.ClusterName)
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %s in region %s is not in the cluster catalog", config.ClusterName, config.Region)
+	}
+	return cluster, nil
+}
+
+// checkCatalogTarget adds an error to errs when the catalog does not list
+// config's cluster in its region, or the cluster does not host its
+// environment.
+func (g *Generator) checkCatalogTarget(errs *ValidationErrors, config *Config) error {
+	catalog, err := g.currentClusterCatalog()
+	if err != nil || catalog == nil {
+		return err
+	}
+	cluster := catalog.lookup(config.Region, config.ClusterName)
+	if cluster == nil {
+		errs.add("ClusterName", config.ClusterName, CodeNotAllowed, "is not in the cluster catalog for region %s", config.Region)
+		return nil
+	}
+	if !cluster.hosts(config.OpEnvironment) {
+		errs.add("OpEnvironment", config.OpEnvironment, CodeNotAllowed, "cluster %s hosts %s only", cluster.Name, strings.Join(cluster.Environments, ", "))
+	}
+	return nil
+}
+
+// checkClusterCapacity rejects a new tenant on a cluster that already holds
+// its catalog maxTenants, counting new tenants still pending. Tenants that
+// already exist are always allowed.
+func (g *Generator) checkClusterCapacity(config *Config, pending []pendingTenant) error {
+	cluster, err := g.clusterFor(config)
+	if err != nil || cluster.Capacity.MaxTenants == 0 {
+		return err
+	}
+	if _, err := os.Stat(g.tenantDir(config)); err == nil {
+		return nil
+	}
+
+	clusterDir := g.clusterDirFor(config)
+	entries, err := os.ReadDir(clusterDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", clusterDir, err)
+	}
+	tenants := 0
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && !isGitOpsFlavourDir(entry.Name()) {
+			tenants++
+		}
+	}
+	for _, tenant := range pending {
+		if _, err := os.Stat(filepath.Join(clusterDir, tenant.Namespace)); os.IsNotExist(err) {
+			tenants++
+		}
+	}
+	if tenants >= cluster.Capacity.MaxTenants {
+		return fmt.Errorf("cluster %s is full: %d of %d tenants", cluster.Name, tenants, cluster.Capacity.MaxTenants)
+	}
+	return nil
+}
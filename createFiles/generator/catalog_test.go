@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCatalogRegionsReplaceBuiltInRegions(t *testing.T) {
+	root := t.TempDir()
+	catalog := "clusters:\n  - name: aks-sec-dev-01\n    region: swedencentral\n    environments: [dev]\n"
+	if err := os.WriteFile(filepath.Join(root, clusterCatalogFile), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{
+			name:   "region only in the catalog",
+			config: Config{OpEnvironment: "dev", Region: "swedencentral", ClusterName: "aks-sec-dev-01", Swci: "ab12", Suffix: "web"},
+		},
+		{
+			name:    "built-in region without catalog clusters",
+			config:  Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"},
+			wantErr: "Region: must be one of swedencentral",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.checkConfig(&tt.config)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("checkConfig() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("checkConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCatalogRegionPairs(t *testing.T) {
+	clusters := "clusters:\n  - name: aks1\n    region: uksouth\n    environments: [dev]\n  - name: aks2\n    region: ukwest\n    environments: [dev]\n"
+	tests := []struct {
+		name    string
+		pairs   string
+		wantErr string
+	}{
+		{name: "no pairs"},
+		{name: "pair of catalog regions", pairs: "regionPairs:\n  uk: [uksouth, ukwest]\n"},
+		{name: "region without clusters", pairs: "regionPairs:\n  europe: [northeurope, westeurope]\n", wantErr: "no cluster in region northeurope"},
+		{name: "region in two pairs", pairs: "regionPairs:\n  a: [uksouth]\n  b: [uksouth, ukwest]\n", wantErr: "region uksouth is already in pair a"},
+		{name: "empty pair", pairs: "regionPairs:\n  uk: []\n", wantErr: "region pair uk: no regions"},
+		{name: "bad pair name", pairs: "regionPairs:\n  UK: [uksouth]\n", wantErr: `region pair "UK"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.WriteFile(filepath.Join(root, clusterCatalogFile), []byte(clusters+tt.pairs), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := New(Options{OutputRoot: root})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Delete offboards a tenant: it removes the directory AddOrModify would have
+// generated for config and drops it from any parent kustomization that still
+// lists it. Every removed path is logged and returned.
+func (g *Generator) Delete(config *Config) (*DeleteResult, error) {
+	removed, err := g.deleteTenant(config)
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteResult{Namespace: tenantNamespace(config), Dir: g.tenantDir(config), Removed: removed}, nil
+}
+
+// deleteTenant does the work of Delete and returns the removed paths:
+// the tenant directory contents deepest first, then any delivery objects.
+func (g *Generator) deleteTenant(config *Config) ([]string, error) {
+	// Only the fields that locate the directory matter, so a tenant stays
+	// removable after its cluster leaves the catalog
+	if err := g.checkLocation(config); err != nil {
+		return nil, err
+	}
+
+	dir := g.tenantDir(config)
+	g.logger.Printf("Target directory: %s", dir)
+
+	// Refuse to touch anything that does not resolve to exactly one tenant
+	// directory below the cluster directory
+	if err := g.checkTenantDir(config, dir); err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		g.logger.Printf("Tenant directory %s does not exist, nothing to remove", dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", dir, err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("refusing to delete %s: not a directory", dir)
+	} else {
+		removed, err = removeTenantDir(dir)
+		for _, path := range removed {
+			g.logger.Printf("Removed: %s", path)
+		}
+		if err != nil {
+			return removed, err
+		}
+		g.logger.Printf("Removed %d paths from %s", len(removed), dir)
+	}
+
+	// Stop the delivery tool from reconciling the removed directory
+	for _, flavour := range gitOpsFlavourNames {
+		ok, err := g.removeGitOpsObjects(config, flavour)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, g.gitOpsFileFor(config, flavour))
+		}
+	}
+
+	// Remove references from the cluster kustomization and its ancestors
+	root := filepath.Clean(g.opts.OutputRoot)
+	for parent := filepath.Dir(dir); ; parent = filepath.Dir(parent) {
+		if file := findKustomizationFile(parent); file != "" {
+			changed, err := g.removeKustomizationResource(file, dir)
+			if err != nil {
+				return removed, fmt.Errorf("failed to update parent kustomization: %v", err)
+			}
+			if changed {
+				g.logger.Printf("Removed %s from %s", tenantNamespace(config), file)
+			}
+		}
+		if parent == root || parent == filepath.Dir(parent) {
+			break
+		}
+	}
+
+	// Release the tenant's hostnames
+	clusterDir := g.clusterDirFor(config)
+	g.hostnameClaimsMu.Lock()
+	changed, err := g.writeHostnameOwnership(clusterDir)
+	g.hostnameClaimsMu.Unlock()
+	if err != nil {
+		return removed, err
+	} else if changed {
+		g.logger.Printf("Updated hostname ownership in %s", filepath.Join(clusterDir, hostnameOwnershipFile))
+	}
+
+	return removed, nil
+}
+
+// checkTenantDir verifies that dir is a direct child of the cluster directory
+// named after the tenant namespace, so a crafted Config cannot point the
+// delete at a parent or sibling directory.
+func (g *Generator) checkTenantDir(config *Config, dir string) error {
+	name := tenantNamespace(config)
+	for _, part := range []string{config.OpEnvironment, config.Region, config.ClusterName, name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return fmt.Errorf("refusing to delete %s: invalid path component %q", dir, part)
+		}
+	}
+
+	rel, err := filepath.Rel(g.clusterDirFor(config), dir)
+	if err != nil || rel != name {
+		return fmt.Errorf("refusing to delete %s: outside cluster directory %s", dir, g.clusterDirFor(config))
+	}
+	return nil
+}
+
+// removeTenantDir removes dir and everything below it, returning the removed
+// paths deepest first. Symlinks are removed as links and never followed.
+func removeTenantDir(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %v", dir, err)
+	}
+
+	var removed []string
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := os.Remove(paths[i]); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %v", paths[i], err)
+		}
+		removed = append(removed, paths[i])
+	}
+	return removed, nil
+}
@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeleteNeedsOnlyTheLocation(t *testing.T) {
+	root := t.TempDir()
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddOrModify(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cluster has since left the catalog
+	catalog := "clusters:\n  - name: aks2\n    region: uksouth\n    environments: [dev]\n"
+	if err := os.WriteFile(filepath.Join(root, clusterCatalogFile), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name:   "fields that do not locate the directory are ignored",
+			config: Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web", FullDomainName: "not a host", GitLabRepoURL: "::"},
+		},
+		{name: "missing suffix", config: Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12"}, wantErr: true},
+		{name: "path in cluster name", config: Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "../aks1", Swci: "ab12", Suffix: "web"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(Options{OutputRoot: root})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = g.Delete(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := os.Stat(g.tenantDir(config)); !os.IsNotExist(err) {
+		t.Errorf("%s exists after Delete", g.tenantDir(config))
+	}
+}
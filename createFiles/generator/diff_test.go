@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns the lines 1 to n, with the lines in replace changed.
+func numberedLines(n int, replace map[int]string) []byte {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = strconv.Itoa(i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return []byte(b.String())
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []byte
+		want string
+	}{
+		{
+			name: "identical",
+			a:    []byte("a\nb\n"),
+			b:    []byte("a\nb\n"),
+			want: "",
+		},
+		{
+			name: "added file",
+			a:    nil,
+			b:    []byte("a\nb\n"),
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed file",
+			a:    []byte("a\nb\n"),
+			b:    nil,
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "appended line",
+			a:    []byte("a\nb\n"),
+			b:    []byte("a\nb\nc\n"),
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n b\n+c\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			a:    numberedLines(10, nil),
+			b:    numberedLines(10, map[int]string{3: "three", 8: "eight"}),
+			want: "--- old\n+++ new\n@@ -1,10 +1,10 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			a:    numberedLines(20, nil),
+			b:    numberedLines(20, map[int]string{5: "five", 16: "sixteen"}),
+			want: "--- old\n+++ new\n" +
+				"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+				"@@ -13,7 +13,7 @@\n 13\n 14\n 15\n-16\n+sixteen\n 17\n 18\n 19\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.a, tt.b); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
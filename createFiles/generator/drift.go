@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Drift kinds.
+const (
+	// driftManualEdit is a file that no longer matches what was generated.
+	driftManualEdit = "manual edit"
+	// driftStaleTemplate is a file still as generated that the current
+	// templates would render differently or no longer produce.
+	driftStaleTemplate = "stale template"
+	// driftExtraFile is a file the generator never produced.
+	driftExtraFile = "extra file"
+	// driftMissingFile is a file the templates produce that is not on disk.
+	driftMissingFile = "missing file"
+	// driftCheckFailed is a tenant that could not be checked at all.
+	driftCheckFailed = "check failed"
+)
+
+// driftFinding is one difference between a tenant directory and its
+// rendering, or a tenant that could not be checked, with Err saying why.
+type driftFinding struct {
+	Dir  string
+	File string
+	Kind string
+	Err  error
+}
+
+// detectDrift renders a tenant in memory and classifies every difference with
+// the committed files, using the checksums in its record to tell hand edits
+// apart from template changes.
+func (g *Generator) detectDrift(tenant discoveredTenant) ([]driftFinding, error) {
+	expected, err := g.renderTenant(tenant.Config, tenant.Record.templateVersion())
+	if err != nil {
+		return nil, err
+	}
+	actual, err := readTenantFiles(tenant.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range actual {
+		if _, ok := expected[name]; !ok && isUserOwned(data) {
+			delete(actual, name)
+		}
+	}
+
+	names := make(map[string]bool)
+	for name := range expected {
+		names[name] = true
+	}
+	for name := range actual {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var findings []driftFinding
+	for _, name := range sorted {
+		want, inExpected := expected[name]
+		got, inActual := actual[name]
+		recorded, wasGenerated := tenant.Record.Files[name]
+		asGenerated := wasGenerated && inActual && contentHash(got) == recorded
+
+		kind := ""
+		switch {
+		case !inActual:
+			kind = driftMissingFile
+		case !inExpected && asGenerated:
+			kind = driftStaleTemplate
+		case !inExpected && !wasGenerated:
+			kind = driftExtraFile
+		case !inExpected:
+			kind = driftManualEdit
+		case string(got) == string(want):
+			continue
+		case asGenerated:
+			kind = driftStaleTemplate
+		default:
+			kind = driftManualEdit
+		}
+		findings = append(findings, driftFinding{Dir: tenant.Dir, File: name, Kind: kind})
+	}
+	return findings, nil
+}
+
+// printDriftReport writes one line per finding and a summary by kind.
+func printDriftReport(w io.Writer, tenants int, findings []driftFinding) {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Kind]++
+		if f.Err != nil {
+			fmt.Fprintf(w, "%-15s %s  %v\n", f.Kind, f.Dir, f.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-15s %s/%s\n", f.Kind, f.Dir, f.File)
+	}
+	fmt.Fprintf(w, "Drift summary: %d tenants checked, %d manual edits, %d stale templates, %d extra files, %d missing files, %d failed\n",
+		tenants, counts[driftManualEdit], counts[driftStaleTemplate], counts[driftExtraFile], counts[driftMissingFile], counts[driftCheckFailed])
+}
+
+// DriftCheck compares every matching tenant with what the templates would
+// produce and writes the findings to w. A tenant that cannot be checked is
+// reported and the others are still checked. It returns an error when any
+// drift or failure is found so a scheduled pipeline job fails.
+func (g *Generator) DriftCheck(w io.Writer, filter TenantFilter) error {
+	tenants, err := g.discoverTenants(g.opts.OutputRoot)
+	if err != nil {
+		return err
+	}
+
+	checked := 0
+	var findings []driftFinding
+	for _, tenant := range tenants {
+		if !filter.matches(tenant.Config, g.envDirFor(tenant.Config.OpEnvironment)) {
+			continue
+		}
+		checked++
+		if tenant.Err != nil {
+			findings = append(findings, driftFinding{Dir: tenant.Dir, Kind: driftCheckFailed, Err: tenant.Err})
+			continue
+		}
+		tenantFindings, err := g.detectDrift(tenant)
+		if err != nil {
+			findings = append(findings, driftFinding{Dir: tenant.Dir, Kind: driftCheckFailed, Err: err})
+			continue
+		}
+		findings = append(findings, tenantFindings...)
+	}
+
+	printDriftReport(w, checked, findings)
+	failed := 0
+	for _, f := range findings {
+		if f.Kind == driftCheckFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("drift check failed for %d tenants", failed)
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("drift detected in %d files", len(findings))
+	}
+	return nil
+}
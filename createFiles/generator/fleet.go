@@ -0,0 +1,293 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TenantFilter narrows fleet operations and List down to matching tenants.
+// Empty fields match everything.
+type TenantFilter struct {
+	Environment string
+	Region      string
+	Cluster     string
+	Swci        string
+	// Namespaces limits the filter to the listed tenant namespaces, e.g. a
+	// canary group.
+	Namespaces []string
+}
+
+// matches reports whether config passes the filter. Environment matches
+// either the operational environment or envDir, the directory it is stored
+// under.
+func (f TenantFilter) matches(config *Config, envDir string) bool {
+	if f.Environment != "" && f.Environment != config.OpEnvironment && f.Environment != envDir {
+		return false
+	}
+	if f.Region != "" && f.Region != config.Region {
+		return false
+	}
+	if f.Cluster != "" && f.Cluster != config.ClusterName {
+		return false
+	}
+	if f.Swci != "" && f.Swci != config.Swci {
+		return false
+	}
+	if len(f.Namespaces) > 0 {
+		for _, ns := range f.Namespaces {
+			if ns == tenantNamespace(config) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// discoveredTenant is a tenant directory found under OutputRoot. Tenants
+// generated before records existed get an empty record, which pins them to
+// legacyTemplateVersion and vouches for none of their files.
+type discoveredTenant struct {
+	Dir    string
+	Record *tenantRecord
+	Config *Config
+	// Legacy is set for directories without a tenant record.
+	Legacy bool
+	// Err is why the tenant record, or for a legacy tenant its inputs, could
+	// not be used. Record is nil then and Config holds what the path gives,
+	// so filters still apply.
+	Err error
+}
+
+// discoverTenants finds every <env>/<region>/<cluster>/<namespace> tenant
+// directory under root. Directories without a tenant record are included
+// with the inputs legacyTenantConfig recovers, or logged and skipped when
+// their path does not name a tenant; hidden staging and backup directories
+// and delivery object directories are ignored. A tenant whose record is
+// unreadable, or whose legacy inputs cannot be recovered, is included with
+// Err set, so one bad directory does not hide the rest of the fleet.
+func (g *Generator) discoverTenants(root string) ([]discoveredTenant, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "*", "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %v", root, err)
+	}
+
+	var tenants []discoveredTenant
+	for _, dir := range dirs {
+		rel, _ := filepath.Rel(root, dir)
+		if hasHiddenPart(rel) || isGitOpsFlavourDir(filepath.Base(dir)) {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, tenantRecordFile)); os.IsNotExist(err) {
+			pathConfig, err := g.configFromTenantPath(root, dir)
+			if err != nil {
+				g.logger.Printf("Skipping %s: %v", dir, err)
+				continue
+			}
+			config, err := g.legacyTenantConfig(root, dir)
+			if err != nil {
+				g.logger.Printf("Cannot recover the inputs of %s: %v", dir, err)
+				err = fmt.Errorf("no %s and %v", tenantRecordFile, err)
+				tenants = append(tenants, discoveredTenant{Dir: dir, Config: pathConfig, Legacy: true, Err: err})
+				continue
+			}
+			g.logger.Printf("Found %s without %s, using template set %s and the inputs in its path and files", dir, tenantRecordFile, legacyTemplateVersion)
+			tenants = append(tenants, discoveredTenant{Dir: dir, Record: &tenantRecord{}, Config: config, Legacy: true})
+			continue
+		}
+
+		record, config, err := g.readDiscoveredRecord(dir)
+		if err != nil {
+			g.logger.Printf("Cannot use the tenant record in %s: %v", dir, err)
+			config, pathErr := g.configFromTenantPath(root, dir)
+			if pathErr != nil {
+				config = &Config{}
+			}
+			tenants = append(tenants, discoveredTenant{Dir: dir, Config: config, Err: err})
+			continue
+		}
+		tenants = append(tenants, discoveredTenant{Dir: dir, Record: record, Config: config})
+	}
+	return tenants, nil
+}
+
+// readDiscoveredRecord reads the tenant record in dir and the Config it
+// holds, which must describe dir.
+func (g *Generator) readDiscoveredRecord(dir string) (*tenantRecord, *Config, error) {
+	record, err := readTenantRecord(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := record.config()
+	if err != nil {
+		return nil, nil, fmt.Errorf("tenant record in %s: %v", dir, err)
+	}
+	if filepath.Clean(g.tenantDir(config)) != filepath.Clean(dir) {
+		return nil, nil, fmt.Errorf("tenant record in %s describes %s", dir, g.tenantDir(config))
+	}
+	return record, config, nil
+}
+
+// legacySourceRepoAnnotation carries GitLabRepoURL in the kustomization of
+// tenants generated from the legacy template set.
+const legacySourceRepoAnnotation = "platform.example.com/source-repo"
+
+// legacyTenantConfig recovers the inputs of a tenant directory without a
+// record. Its path gives everything but FullDomainName, which is the one
+// hostname the tenant routes, and GitLabRepoURL, which is the source-repo
+// annotation of its kustomization.
+func (g *Generator) legacyTenantConfig(root, dir string) (*Config, error) {
+	config, err := g.configFromTenantPath(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	if findKustomizationFile(dir) == "" {
+		return nil, fmt.Errorf("no kustomization")
+	}
+
+	hosts, err := scanHostnames(dir)
+	if err != nil {
+		return nil, err
+	}
+	switch len(hosts) {
+	case 0:
+	case 1:
+		config.FullDomainName = hosts[0]
+	default:
+		return nil, fmt.Errorf("routes %s, cannot tell which is FullDomainName", strings.Join(hosts, ", "))
+	}
+
+	data, err := os.ReadFile(findKustomizationFile(dir))
+	if err != nil {
+		return nil, err
+	}
+	var kustomization struct {
+		CommonAnnotations map[string]string `yaml:"commonAnnotations"`
+	}
+	if err := yaml.Unmarshal(data, &kustomization); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", findKustomizationFile(dir), err)
+	}
+	config.GitLabRepoURL = kustomization.CommonAnnotations[legacySourceRepoAnnotation]
+	return config, nil
+}
+
+// hasHiddenPart reports whether any element of a relative path starts with a
+// dot.
+func hasHiddenPart(rel string) bool {
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Re-render statuses.
+const (
+	rerenderChanged   = "changed"
+	rerenderUnchanged = "unchanged"
+	rerenderFailed    = "failed"
+)
+
+// rerenderResult is the outcome of re-rendering one tenant.
+type rerenderResult struct {
+	Dir    string
+	Status string
+	Plan   *tenantPlan
+	Err    error
+}
+
+// rerenderTenants re-renders every matching tenant from its stored inputs with
+// the current templates. Only tenants whose output changes are rewritten, and
+// with dryRun nothing is written at all.
+func (g *Generator) rerenderTenants(filter TenantFilter, dryRun bool) ([]rerenderResult, error) {
+	tenants, err := g.discoverTenants(g.opts.OutputRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []rerenderResult
+	for _, tenant := range tenants {
+		if !filter.matches(tenant.Config, g.envDirFor(tenant.Config.OpEnvironment)) {
+			continue
+		}
+
+		result := rerenderResult{Dir: tenant.Dir}
+		if tenant.Err != nil {
+			result.Status = rerenderFailed
+			result.Err = tenant.Err
+			results = append(results, result)
+			continue
+		}
+		plan, err := g.planTenant(tenant.Config, tenant.Record.templateVersion())
+		switch {
+		case err != nil:
+			result.Status = rerenderFailed
+			result.Err = err
+		case !plan.hasChanges():
+			result.Status = rerenderUnchanged
+		default:
+			result.Status = rerenderChanged
+			result.Plan = plan
+			if !dryRun {
+				if err := g.AddOrModify(tenant.Config); err != nil {
+					result.Status = rerenderFailed
+					result.Err = err
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// printRerenderReport lists every tenant with its status, including the plan
+// for tenants that changed.
+func printRerenderReport(w io.Writer, results []rerenderResult, dryRun bool) {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Plan != nil && dryRun {
+			printPlan(w, r.Plan)
+		}
+	}
+	for _, r := range results {
+		line := fmt.Sprintf("%-10s %s", r.Status, r.Dir)
+		if r.Err != nil {
+			line += "  " + r.Err.Error()
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	mode := ""
+	if dryRun {
+		mode = " (dry run)"
+	}
+	fmt.Fprintf(w, "Re-render summary%s: %d tenants, %d changed, %d unchanged, %d failed\n",
+		mode, len(results), counts[rerenderChanged], counts[rerenderUnchanged], counts[rerenderFailed])
+}
+
+// Rerender re-renders all tenants matching filter with the current overlay
+// templates and reports to w which of them changed.
+func (g *Generator) Rerender(w io.Writer, filter TenantFilter, dryRun bool) error {
+	results, err := g.rerenderTenants(filter, dryRun)
+	if err != nil {
+		return err
+	}
+	printRerenderReport(w, results, dryRun)
+
+	for _, r := range results {
+		if r.Status == rerenderFailed {
+			return fmt.Errorf("re-render failed for one or more tenants")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLegacyTenant generates config from the legacy template set and drops
+// its record, as tenants generated before records existed look.
+func writeLegacyTenant(t *testing.T, root string, config *Config) {
+	t.Helper()
+	g, err := New(Options{OutputRoot: root, TemplateVersion: legacyTemplateVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddOrModify(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(g.tenantDir(config), tenantRecordFile)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLegacyTenantsAreDiscovered(t *testing.T) {
+	root := t.TempDir()
+	config := &Config{
+		OpEnvironment:  "dev",
+		Region:         "uksouth",
+		ClusterName:    "aks1",
+		Swci:           "ab12",
+		Suffix:         "web",
+		FullDomainName: "web.apps.example.com",
+	}
+	writeLegacyTenant(t, root, config)
+
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := g.pinnedTemplateVersion(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != legacyTemplateVersion {
+		t.Errorf("pinnedTemplateVersion() = %s, want %s", version, legacyTemplateVersion)
+	}
+
+	tenants, err := g.discoverTenants(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 1 {
+		t.Fatalf("discoverTenants() found %d tenants, want 1", len(tenants))
+	}
+	if got := tenants[0].Config; *got != *config {
+		t.Errorf("discoverTenants() config = %+v, want %+v", *got, *config)
+	}
+	if got := tenants[0].Record.templateVersion(); got != legacyTemplateVersion {
+		t.Errorf("discovered template set = %s, want %s", got, legacyTemplateVersion)
+	}
+
+	var out bytes.Buffer
+	if err := g.Upgrade(&out, TenantFilter{}, "", true); err != nil {
+		t.Fatalf("Upgrade() error = %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "1 moved") {
+		t.Errorf("Upgrade() did not move the legacy tenant:\n%s", out.String())
+	}
+}
+
+func TestDriftCheckReportsUnreadableTenants(t *testing.T) {
+	root := t.TempDir()
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "api"}
+	edited := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	for _, config := range []*Config{broken, edited} {
+		if err := g.AddOrModify(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(g.tenantDir(broken), tenantRecordFile), []byte("files: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kustomization := filepath.Join(g.tenantDir(edited), "kustomization.yaml")
+	if err := os.WriteFile(kustomization, []byte("# edited by hand\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := g.DriftCheck(&out, TenantFilter{}); err == nil {
+		t.Fatalf("DriftCheck() found no problems:\n%s", out.String())
+	}
+	for _, want := range []string{
+		driftCheckFailed + "    " + g.tenantDir(broken),
+		driftManualEdit + "     " + kustomization,
+		"2 tenants checked, 1 manual edits",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("DriftCheck() report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
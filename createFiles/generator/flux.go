@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"fmt"
+)
+
+const fluxFlavourName = "flux"
+
+// fluxKustomizationOptions are the knobs of the generated Flux objects.
+type fluxKustomizationOptions struct {
+	// Namespace the Flux objects live in, flux-system by default.
+	Namespace string `yaml:"namespace,omitempty"`
+	// SourceName and SourceNamespace name the GitRepository holding this repo.
+	SourceName      string `yaml:"sourceName"`
+	SourceNamespace string `yaml:"sourceNamespace,omitempty"`
+	Interval        string `yaml:"interval"`
+	Timeout         string `yaml:"timeout,omitempty"`
+	Prune           bool   `yaml:"prune"`
+	// DependsOn lists Kustomizations in Namespace that must be ready first.
+	DependsOn    []string          `yaml:"dependsOn,omitempty"`
+	HealthChecks []fluxHealthCheck `yaml:"healthChecks,omitempty"`
+	// ServiceAccountName is impersonated when applying, so a tenant can only
+	// change what its service account is allowed to.
+	ServiceAccountName string `yaml:"serviceAccountName,omitempty"`
+	// DefaultBranch is tracked by the tenant GitRepository when the tenant
+	// has its own repository.
+	DefaultBranch string `yaml:"defaultBranch,omitempty"`
+}
+
+// fluxHealthCheck references an object Flux waits on after applying. An empty
+// Namespace means the tenant namespace.
+type fluxHealthCheck struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+type fluxObjectMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type fluxCrossNamespaceRef struct {
+	Kind      string `yaml:"kind,omitempty"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type fluxKustomizationSpec struct {
+	Interval           string                  `yaml:"interval"`
+	Timeout            string                  `yaml:"timeout,omitempty"`
+	Path               string                  `yaml:"path"`
+	Prune              bool                    `yaml:"prune"`
+	SourceRef          fluxCrossNamespaceRef   `yaml:"sourceRef"`
+	DependsOn          []fluxCrossNamespaceRef `yaml:"dependsOn,omitempty"`
+	HealthChecks       []fluxHealthCheck       `yaml:"healthChecks,omitempty"`
+	ServiceAccountName string                  `yaml:"serviceAccountName,omitempty"`
+}
+
+type fluxKustomization struct {
+	APIVersion string                `yaml:"apiVersion"`
+	Kind       string                `yaml:"kind"`
+	Metadata   fluxObjectMeta        `yaml:"metadata"`
+	Spec       fluxKustomizationSpec `yaml:"spec"`
+}
+
+type fluxGitRepositoryRef struct {
+	Branch string `yaml:"branch"`
+}
+
+type fluxGitRepositorySpec struct {
+	Interval string               `yaml:"interval"`
+	URL      string               `yaml:"url"`
+	Ref      fluxGitRepositoryRef `yaml:"ref"`
+}
+
+type fluxGitRepository struct {
+	APIVersion string                `yaml:"apiVersion"`
+	Kind       string                `yaml:"kind"`
+	Metadata   fluxObjectMeta        `yaml:"metadata"`
+	Spec       fluxGitRepositorySpec `yaml:"spec"`
+}
+
+// fluxFlavour delivers tenants with a Flux Kustomization, plus a
+// GitRepository for tenants that bring their own repository.
+type fluxFlavour struct {
+	opts      *fluxKustomizationOptions
+	hostKinds map[string]string
+}
+
+func (f *fluxFlavour) Name() string { return fluxFlavourName }
+
+func (f *fluxFlavour) namespace() string {
+	if f.opts.Namespace == "" {
+		return "flux-system"
+	}
+	return f.opts.Namespace
+}
+
+// Render builds the Flux Kustomization reconciling a tenant's directory, with
+// the path derived from the same env/region/cluster/namespace computation as
+// the directory itself.
+func (f *fluxFlavour) Render(config *Config, path string) ([]byte, error) {
+	k, err := f.kustomization(config, path)
+	if err != nil {
+		return nil, err
+	}
+	objects := []interface{}{k}
+	if config.GitLabRepoURL != "" {
+		objects = append(objects, f.gitRepository(config))
+	}
+	return encodeYAMLStream(objects...)
+}
+
+func (f *fluxFlavour) kustomization(config *Config, path string) (*fluxKustomization, error) {
+	opts := f.opts
+	if opts.SourceName == "" {
+		return nil, fmt.Errorf("flux settings: sourceName is required")
+	}
+	if opts.Interval == "" {
+		return nil, fmt.Errorf("flux settings: interval is required")
+	}
+
+	k := &fluxKustomization{
+		APIVersion: "kustomize.toolkit.fluxcd.io/v1",
+		Kind:       "Kustomization",
+		Metadata:   fluxObjectMeta{Name: tenantNamespace(config), Namespace: f.namespace()},
+		Spec: fluxKustomizationSpec{
+			Interval:           opts.Interval,
+			Timeout:            opts.Timeout,
+			Path:               path,
+			Prune:              opts.Prune,
+			SourceRef:          fluxCrossNamespaceRef{Kind: "GitRepository", Name: opts.SourceName, Namespace: opts.SourceNamespace},
+			ServiceAccountName: opts.ServiceAccountName,
+		},
+	}
+	for _, dep := range opts.DependsOn {
+		k.Spec.DependsOn = append(k.Spec.DependsOn, fluxCrossNamespaceRef{Name: dep})
+	}
+	for _, check := range opts.HealthChecks {
+		if check.Namespace == "" {
+			check.Namespace = tenantNamespace(config)
+		}
+		k.Spec.HealthChecks = append(k.Spec.HealthChecks, check)
+	}
+	return k, nil
+}
+
+// gitRepository builds the source for the tenant's own repository, which the
+// tenant overlay can reference by the tenant namespace name. It tracks the
+// branch named in the URL, falling back to DefaultBranch and then main.
+func (f *fluxFlavour) gitRepository(config *Config) *fluxGitRepository {
+	repo := repoSourceFor(config, f.hostKinds)
+	branch := repo.Branch
+	if branch == "" {
+		branch = f.opts.DefaultBranch
+	}
+	if branch == "" {
+		branch = "main"
+	}
+	return &fluxGitRepository{
+		APIVersion: "source.toolkit.fluxcd.io/v1",
+		Kind:       "GitRepository",
+		Metadata:   fluxObjectMeta{Name: tenantNamespace(config), Namespace: f.namespace()},
+		Spec: fluxGitRepositorySpec{
+			Interval: f.opts.Interval,
+			URL:      repo.CloneURL,
+			Ref:      fluxGitRepositoryRef{Branch: branch},
+		},
+	}
+}
@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs is the function library available to overlay templates, on
+// top of the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"split":   func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":    joinValues,
+	"quote":   func(s string) string { return fmt.Sprintf("%q", s) },
+	"default": defaultValue,
+	"required": func(msg string, v interface{}) (interface{}, error) {
+		if isEmptyValue(v) {
+			return nil, fmt.Errorf("required value missing: %s", msg)
+		}
+		return v, nil
+	},
+	"indent":  indentLines,
+	"nindent": func(n int, s string) string { return "\n" + indentLines(n, s) },
+	"toYaml":  toYAML,
+
+	"dnsLabel":   dnsLabel,
+	"hostLabel":  func(host string) string { return strings.SplitN(host, ".", 2)[0] },
+	"hostDomain": hostDomain,
+	"hostUnder":  hostUnder,
+}
+
+// defaultValue returns v, or def when v is empty. Arguments follow the
+// pipeline order: {{ .Suffix | default "app" }}.
+func defaultValue(def, v interface{}) interface{} {
+	if isEmptyValue(v) {
+		return def
+	}
+	return v
+}
+
+// isEmptyValue treats nil, "" and empty slices and maps as missing.
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []string:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// joinValues joins a list with sep: {{ .List | join "," }}.
+func joinValues(sep string, list interface{}) (string, error) {
+	switch list := list.(type) {
+	case []string:
+		return strings.Join(list, sep), nil
+	case []interface{}:
+		parts := make([]string, len(list))
+		for i, v := range list {
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, sep), nil
+	}
+	return "", fmt.Errorf("join: cannot join %T", list)
+}
+
+// indentLines prefixes every non-empty line of s with n spaces.
+func indentLines(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toYAML encodes v with the repo's YAML style, without the trailing newline so
+// it composes with indent.
+func toYAML(v interface{}) (string, error) {
+	data, err := encodeYAML(v)
+	if err != nil {
+		return "", fmt.Errorf("toYaml: %v", err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+var dnsLabelInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// dnsLabel turns s into a valid DNS-1123 label: lowercased, other characters
+// replaced by '-', trimmed and cut to 63 characters.
+func dnsLabel(s string) string {
+	label := dnsLabelInvalid.ReplaceAllString(strings.ToLower(s), "-")
+	if len(label) > maxLabelLength {
+		label = label[:maxLabelLength]
+	}
+	return strings.Trim(label, "-")
+}
+
+// hostDomain returns host without its first label: "a.example.com" gives
+// "example.com".
+func hostDomain(host string) string {
+	parts := strings.SplitN(host, ".", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// hostUnder reports whether host is domain or one of its subdomains.
+func hostUnder(domain, host string) bool {
+	host, domain = strings.ToLower(host), strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
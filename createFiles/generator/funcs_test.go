@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"Name":  "Web App",
+		"Empty": "",
+		"List":  []string{"a", "b"},
+		"Host":  "web.apps.example.com",
+		"Map":   map[string]interface{}{"key": "value"},
+	}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "lower", text: `{{ .Name | lower }}`, want: "web app"},
+		{name: "upper", text: `{{ .Name | upper }}`, want: "WEB APP"},
+		{name: "trim", text: `{{ " x " | trim }}`, want: "x"},
+		{name: "replace", text: `{{ .Name | replace " " "-" }}`, want: "Web-App"},
+		{name: "split and join", text: `{{ "a,b" | split "," | join "+" }}`, want: "a+b"},
+		{name: "join", text: `{{ .List | join "," }}`, want: "a,b"},
+		{name: "join rejects scalars", text: `{{ .Name | join "," }}`, wantErr: true},
+		{name: "quote", text: `{{ .Name | quote }}`, want: `"Web App"`},
+		{name: "default on empty", text: `{{ .Empty | default "app" }}`, want: "app"},
+		{name: "default keeps value", text: `{{ .Name | default "app" }}`, want: "Web App"},
+		{name: "required passes value", text: `{{ .Name | required "name" }}`, want: "Web App"},
+		{name: "required fails on empty", text: `{{ .Empty | required "empty" }}`, wantErr: true},
+		{name: "indent", text: `{{ "a\n\nb" | indent 2 }}`, want: "  a\n\n  b"},
+		{name: "nindent", text: `{{ "a" | nindent 2 }}`, want: "\n  a"},
+		{name: "toYaml", text: `{{ .Map | toYaml }}`, want: "key: value"},
+		{name: "dnsLabel", text: `{{ .Name | dnsLabel }}`, want: "web-app"},
+		{name: "dnsLabel cuts long names", text: `{{ "` + strings.Repeat("a", 70) + `" | dnsLabel }}`, want: strings.Repeat("a", maxLabelLength)},
+		{name: "hostLabel", text: `{{ .Host | hostLabel }}`, want: "web"},
+		{name: "hostDomain", text: `{{ .Host | hostDomain }}`, want: "apps.example.com"},
+		{name: "hostDomain of a bare label", text: `{{ "web" | hostDomain }}`, want: ""},
+		{name: "hostUnder", text: `{{ hostUnder "example.com" .Host }}`, want: "true"},
+		{name: "hostUnder needs a label boundary", text: `{{ hostUnder "example.com" "webexample.com" }}`, want: "false"},
+		{name: "missing key", text: `{{ .Missing }}`, wantErr: true},
+		{name: "missing nested key", text: `{{ .Map.other }}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(templateFuncs).Option("missingkey=error").Parse(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if err == nil && out.String() != tt.want {
+				t.Errorf("%s = %q, want %q", tt.text, out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateFileIsStrict(t *testing.T) {
+	templateDir := t.TempDir()
+	g, err := New(Options{OutputRoot: t.TempDir(), TemplateDir: templateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := g.resolveTemplateVersion("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(templateDir, version), 0755); err != nil {
+		t.Fatal(err)
+	}
+	templates := map[string]string{
+		"unknown-field.yaml": "name: {{ .Unknown }}\n",
+		"cluster-field.yaml": "revision: {{ .Cluster.Missing }}\n",
+		"known-fields.yaml":  "name: {{ .Namespace }}\nhost: {{ .FullDomainName | default \"none\" }}\n",
+	}
+	for name, text := range templates {
+		if err := os.WriteFile(filepath.Join(templateDir, version, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+
+	for _, name := range []string{"unknown-field.yaml", "cluster-field.yaml"} {
+		if out, err := g.renderTemplateFile(version, name, config); err == nil {
+			t.Errorf("renderTemplateFile(%s) = %q, want an error instead of a placeholder", name, out)
+		}
+	}
+	out, err := g.renderTemplateFile(version, "known-fields.yaml", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "name: ab12-dev-web\nhost: none\n"; string(out) != want {
+		t.Errorf("renderTemplateFile(known-fields.yaml) = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,305 @@
+// Package generator renders tenant overlay directories from template sets and
+// keeps them, their cluster kustomizations and GitOps delivery objects up to
+// date. The createFiles command is a thin wrapper around it; pipelines, the
+// self-service portal and tests use it directly.
+package generator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes one tenant.
+type Config struct {
+	OpEnvironment  string
+	Region         string
+	ClusterName    string
+	Swci           string
+	Suffix         string
+	FullDomainName string
+	GitLabRepoURL  string
+}
+
+// Options configure a Generator.
+type Options struct {
+	// OutputRoot is the environments tree tenants are written to. Required.
+	OutputRoot string
+	// TemplateDir overrides and extends the embedded template sets. Empty
+	// uses the embedded sets only.
+	TemplateDir string
+	// TemplateVersion is the template set new tenants are pinned to. Empty
+	// means the highest version available, so a new set only reaches new
+	// tenants once it is released, and existing ones once they are upgraded.
+	TemplateVersion string
+	// EnvironmentDirs maps an OpEnvironment onto the directory it is stored
+	// under. Nil keeps the built-in mapping of test onto dev.
+	EnvironmentDirs map[string]string
+	// GitOpsRepoRoot is the root of the Git repository the delivery tool
+	// syncs from. Tenant paths in the generated objects are relative to it.
+	// Empty means the current directory.
+	GitOpsRepoRoot string
+	// OrphanMode decides what a modify does with files the templates no
+	// longer produce: OrphansRemove deletes them, OrphansReport only logs
+	// them. Empty means OrphansRemove.
+	OrphanMode string
+	// BuildOutput controls where the built manifest stream of each generated
+	// tenant goes: empty to discard it, "-" for BuildWriter, or a directory
+	// that receives one <environment>_<region>_<cluster>_<namespace>.yaml per
+	// tenant.
+	BuildOutput string
+	// BuildWriter receives the built manifests when BuildOutput is "-".
+	// Required in that case.
+	BuildWriter io.Writer
+	// RepoHostKinds classifies self-hosted Git hosts whose names give no
+	// hint of their kind, e.g. "git.internal.example.com": "gitlab". Kinds
+	// are gitlab, github, azuredevops and generic.
+	RepoHostKinds map[string]string
+	// Logger receives the progress log. Nil discards it.
+	Logger *log.Logger
+}
+
+// Generator plans, applies, deletes and lists tenants under one OutputRoot.
+// Its methods are safe for concurrent use. Settings files and variant rules
+// are read and checked by New; create a new Generator to pick up changes to
+// them.
+// Templates are read from the embedded sets and TemplateDir as they are
+// rendered, and nothing is written outside OutputRoot.
+type Generator struct {
+	opts   Options
+	logger *log.Logger
+
+	variantRulesMu        sync.Mutex
+	variantRulesByVersion map[string][]variantRule
+
+	gitOpsSettingsOnce sync.Once
+	gitOpsLoaded       *gitOpsSettings
+	gitOpsLoadErr      error
+
+	routingSettingsOnce sync.Once
+	routingLoaded       *routingSettings
+	routingLoadErr      error
+
+	clusterCatalogOnce sync.Once
+	catalogLoaded      *clusterCatalog
+	catalogLoadErr     error
+
+	// hostnameClaimsMu is held around the hostname claim check and the
+	// ownership-map write. It also guards pendingTenants, the tenants that
+	// passed the check but are not in their cluster directory yet, keyed by
+	// cluster directory, so tenants generated in parallel cannot claim the
+	// same host or overfill a cluster.
+	hostnameClaimsMu sync.Mutex
+	pendingTenants   map[string][]pendingTenant
+	// parentKustomizationMu serialises edits to shared cluster
+	// kustomizations when tenants are generated in parallel.
+	parentKustomizationMu sync.Mutex
+	// buildWriterMu keeps the build output of parallel tenants apart.
+	buildWriterMu sync.Mutex
+}
+
+// New checks opts, loads the settings and variant rules they point at and
+// returns a Generator using them.
+func New(opts Options) (*Generator, error) {
+	if opts.OutputRoot == "" {
+		return nil, fmt.Errorf("generator: OutputRoot is required")
+	}
+	if opts.EnvironmentDirs == nil {
+		opts.EnvironmentDirs = defaultEnvironmentDirs
+	}
+	if opts.GitOpsRepoRoot == "" {
+		opts.GitOpsRepoRoot = "."
+	}
+	switch opts.OrphanMode {
+	case "":
+		opts.OrphanMode = OrphansRemove
+	case OrphansRemove, OrphansReport:
+	default:
+		return nil, fmt.Errorf("generator: unknown OrphanMode %q, use %s or %s", opts.OrphanMode, OrphansRemove, OrphansReport)
+	}
+	if opts.BuildOutput == "-" && opts.BuildWriter == nil {
+		return nil, fmt.Errorf("generator: BuildOutput %q needs a BuildWriter", opts.BuildOutput)
+	}
+	hostKinds := make(map[string]string, len(opts.RepoHostKinds))
+	for host, kind := range opts.RepoHostKinds {
+		switch kind {
+		case repoKindGitLab, repoKindGitHub, repoKindAzureDevOps, repoKindGeneric:
+		default:
+			return nil, fmt.Errorf("generator: RepoHostKinds: unknown kind %q for %s", kind, host)
+		}
+		hostKinds[strings.ToLower(host)] = kind
+	}
+	opts.RepoHostKinds = hostKinds
+	if opts.Logger == nil {
+		opts.Logger = log.New(io.Discard, "", 0)
+	}
+	g := &Generator{
+		opts:                  opts,
+		logger:                opts.Logger,
+		variantRulesByVersion: make(map[string][]variantRule),
+		pendingTenants:        make(map[string][]pendingTenant),
+	}
+	if err := g.loadSettings(); err != nil {
+		return nil, fmt.Errorf("generator: %v", err)
+	}
+	return g, nil
+}
+
+// loadSettings reads and checks the variant rules of every template set and
+// the settings files in OutputRoot, so a broken file fails New rather than
+// the first tenant that needs it.
+func (g *Generator) loadSettings() error {
+	if _, err := g.resolveTemplateVersion(""); err != nil {
+		return err
+	}
+	versions, err := g.templateVersions()
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if _, err := g.variantRulesFor(version); err != nil {
+			return err
+		}
+	}
+	if _, err := g.currentGitOpsSettings(); err != nil {
+		return err
+	}
+	if _, err := g.cachedRoutingSettings(); err != nil {
+		return err
+	}
+	_, err = g.currentClusterCatalog()
+	return err
+}
+
+// FileChange is the planned or applied outcome for one tenant file.
+type FileChange struct {
+	Name string `json:"name"`
+	// Status is added, changed, unchanged or removed.
+	Status string `json:"status"`
+	// Diff is a unified diff, empty for unchanged files.
+	Diff string `json:"diff,omitempty"`
+}
+
+// PlanResult describes what applying a tenant would change.
+type PlanResult struct {
+	Namespace       string       `json:"namespace"`
+	Dir             string       `json:"dir"`
+	TemplateVersion string       `json:"templateVersion"`
+	Files           []FileChange `json:"files"`
+}
+
+// Changed reports whether any file would be written or removed.
+func (r *PlanResult) Changed() bool {
+	for _, f := range r.Files {
+		if f.Status != planUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyResult describes a generated tenant.
+type ApplyResult struct {
+	PlanResult
+	Variant string `json:"variant"`
+	GitOps  string `json:"gitops"`
+}
+
+// DeleteResult lists what deleting a tenant removed.
+type DeleteResult struct {
+	Namespace string   `json:"namespace"`
+	Dir       string   `json:"dir"`
+	Removed   []string `json:"removed"`
+}
+
+// TenantSummary describes a tenant found under OutputRoot.
+type TenantSummary struct {
+	Namespace   string `json:"namespace"`
+	Dir         string `json:"dir"`
+	Environment string `json:"environment"`
+	Region      string `json:"region"`
+	Cluster     string `json:"cluster"`
+	Swci        string `json:"swci"`
+	Suffix      string `json:"suffix"`
+	Domain      string `json:"domain,omitempty"`
+	RepoURL     string `json:"repoURL,omitempty"`
+	RepoKind    string `json:"repoKind,omitempty"`
+	// Managed is false for directories without a tenant record, which are
+	// described from their path and files.
+	Managed         bool       `json:"managed"`
+	Variant         string     `json:"variant,omitempty"`
+	TemplateVersion string     `json:"templateVersion,omitempty"`
+	GitOps          string     `json:"gitops,omitempty"`
+	GeneratedAt     *time.Time `json:"generatedAt,omitempty"`
+}
+
+// newPlanResult converts a tenant plan.
+func newPlanResult(config *Config, version string, plan *tenantPlan) PlanResult {
+	result := PlanResult{Namespace: tenantNamespace(config), Dir: plan.Dir, TemplateVersion: version}
+	for _, f := range plan.Files {
+		result.Files = append(result.Files, FileChange{Name: f.Name, Status: f.Status, Diff: f.Diff})
+	}
+	return result
+}
+
+// Plan renders a tenant in memory and diffs it against its directory.
+func (g *Generator) Plan(config *Config) (*PlanResult, error) {
+	if err := g.checkConfig(config); err != nil {
+		return nil, err
+	}
+	version, err := g.pinnedTemplateVersion(config)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := g.planTenant(config, version)
+	if err != nil {
+		return nil, err
+	}
+	result := newPlanResult(config, version, plan)
+	return &result, nil
+}
+
+// Apply generates a tenant, as AddOrModify does, and returns what changed.
+func (g *Generator) Apply(config *Config) (*ApplyResult, error) {
+	if err := g.checkConfig(config); err != nil {
+		return nil, err
+	}
+	version, err := g.pinnedTemplateVersion(config)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := g.planTenant(config, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.generateTenant(config, version); err != nil {
+		return nil, err
+	}
+	record, err := readTenantRecord(g.tenantDir(config))
+	if err != nil {
+		return nil, err
+	}
+	return &ApplyResult{PlanResult: newPlanResult(config, version, plan), Variant: record.Variant, GitOps: record.GitOps}, nil
+}
+
+// List returns the tenants under OutputRoot matching filter, sorted by
+// namespace.
+func (g *Generator) List(filter TenantFilter) ([]TenantSummary, error) {
+	tenants, err := g.scanInventory(g.opts.OutputRoot)
+	if err != nil {
+		return nil, err
+	}
+	var result []TenantSummary
+	for _, tenant := range tenants {
+		if filter.matches(tenant.Config, g.envDirFor(tenant.Config.OpEnvironment)) {
+			result = append(result, tenant.Summary)
+		}
+	}
+	if err := sortInventory(result, ""); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRejectsBrokenSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		wantErr string
+	}{
+		{name: "variant rules of an old set", file: "templates/v2/" + variantRulesFile, data: "rules: [", wantErr: "template set v2"},
+		{name: "gitops settings", file: gitOpsSettingsFile, data: "default: [", wantErr: gitOpsSettingsFile},
+		{name: "routing settings", file: routingSettingsFile, data: "default: [", wantErr: routingSettingsFile},
+		{name: "cluster catalog", file: clusterCatalogFile, data: "clusters: []\n", wantErr: "no clusters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, tt.file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := New(Options{OutputRoot: root, TemplateDir: filepath.Join(root, "templates")})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
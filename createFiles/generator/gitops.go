@@ -0,0 +1,254 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gitOpsSettingsFile, looked up in OutputRoot, selects the delivery tool
+// per cluster. Without it no delivery objects are generated.
+const gitOpsSettingsFile = "gitops.yaml"
+
+// gitOpsSettings is the parsed gitOpsSettingsFile.
+type gitOpsSettings struct {
+	// Default is the flavour for clusters not listed in Clusters: "flux",
+	// "argocd" or "none".
+	Default string `yaml:"default"`
+	// Clusters overrides the flavour per cluster name.
+	Clusters map[string]string         `yaml:"clusters,omitempty"`
+	Flux     *fluxKustomizationOptions `yaml:"flux,omitempty"`
+	ArgoCD   *argoCDOptions            `yaml:"argocd,omitempty"`
+}
+
+// gitOpsFlavour renders the objects that make one delivery tool reconcile a
+// tenant directory.
+type gitOpsFlavour interface {
+	// Name is the value used in gitOpsSettings.
+	Name() string
+	// Render returns the delivery objects for a tenant as a YAML stream.
+	// path is the tenant directory relative to the repository root.
+	Render(config *Config, path string) ([]byte, error)
+}
+
+const gitOpsNone = "none"
+
+// gitOpsFlavourNames lists every flavour so stale objects can be cleaned up
+// when a cluster switches tools.
+var gitOpsFlavourNames = []string{fluxFlavourName, argoCDFlavourName}
+
+// loadGitOpsSettings reads and validates the settings file in dir. A missing
+// file yields settings that generate nothing.
+func (g *Generator) loadGitOpsSettings(dir string) (*gitOpsSettings, error) {
+	path := filepath.Join(dir, gitOpsSettingsFile)
+	settings := &gitOpsSettings{Default: gitOpsNone}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		g.logger.Printf("No %s found, not generating GitOps delivery objects", path)
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if settings.Default == "" {
+		settings.Default = gitOpsNone
+	}
+
+	flavours := []string{settings.Default}
+	for _, flavour := range settings.Clusters {
+		flavours = append(flavours, flavour)
+	}
+	for _, flavour := range flavours {
+		switch flavour {
+		case gitOpsNone:
+		case fluxFlavourName:
+			if settings.Flux == nil {
+				return nil, fmt.Errorf("%s: flux is used but has no flux settings", path)
+			}
+		case argoCDFlavourName:
+			if settings.ArgoCD == nil {
+				return nil, fmt.Errorf("%s: argocd is used but has no argocd settings", path)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown GitOps flavour %q, use %s or %s", path, flavour, strings.Join(gitOpsFlavourNames, ", "), gitOpsNone)
+		}
+	}
+	g.logger.Printf("Loaded GitOps settings from %s", path)
+	return settings, nil
+}
+
+// currentGitOpsSettings loads the settings once per Generator.
+func (g *Generator) currentGitOpsSettings() (*gitOpsSettings, error) {
+	g.gitOpsSettingsOnce.Do(func() {
+		g.gitOpsLoaded, g.gitOpsLoadErr = g.loadGitOpsSettings(g.opts.OutputRoot)
+	})
+	return g.gitOpsLoaded, g.gitOpsLoadErr
+}
+
+// gitOpsFlavourFor returns the delivery tool configured for the tenant's
+// cluster, or nil when none is.
+func (g *Generator) gitOpsFlavourFor(config *Config) (gitOpsFlavour, error) {
+	settings, err := g.currentGitOpsSettings()
+	if err != nil {
+		return nil, err
+	}
+	name := settings.Default
+	if override, ok := settings.Clusters[config.ClusterName]; ok {
+		name = override
+	}
+	cluster, err := g.clusterFor(config)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.GitOps != "" {
+		name = cluster.GitOps
+	}
+
+	switch name {
+	case fluxFlavourName:
+		if settings.Flux == nil {
+			return nil, fmt.Errorf("cluster %s uses flux but %s has no flux settings", config.ClusterName, gitOpsSettingsFile)
+		}
+		return &fluxFlavour{opts: settings.Flux, hostKinds: g.opts.RepoHostKinds}, nil
+	case argoCDFlavourName:
+		if settings.ArgoCD == nil {
+			return nil, fmt.Errorf("cluster %s uses argocd but %s has no argocd settings", config.ClusterName, gitOpsSettingsFile)
+		}
+		return &argoCDFlavour{opts: settings.ArgoCD, hostKinds: g.opts.RepoHostKinds}, nil
+	}
+	return nil, nil
+}
+
+// gitOpsDirFor returns the directory inside the cluster directory that holds
+// a flavour's delivery objects.
+func (g *Generator) gitOpsDirFor(config *Config, flavour string) string {
+	return filepath.Join(g.clusterDirFor(config), flavour)
+}
+
+// gitOpsFileFor returns the file holding a tenant's delivery objects.
+func (g *Generator) gitOpsFileFor(config *Config, flavour string) string {
+	return filepath.Join(g.gitOpsDirFor(config, flavour), tenantNamespace(config)+".yaml")
+}
+
+// repoPathFor returns the tenant directory relative to GitOpsRepoRoot in the
+// "./a/b" form both Flux and Argo CD accept. Both are made absolute first, so
+// a relative root works with an absolute OutputRoot and the other way round.
+func (g *Generator) repoPathFor(config *Config) (string, error) {
+	root, err := filepath.Abs(g.opts.GitOpsRepoRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve GitOps repository root %s: %v", g.opts.GitOpsRepoRoot, err)
+	}
+	dir, err := filepath.Abs(g.tenantDir(config))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve tenant directory %s: %v", g.tenantDir(config), err)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("tenant directory %s is not inside the GitOps repository root %s", dir, root)
+	}
+	return "./" + filepath.ToSlash(rel), nil
+}
+
+// encodeYAMLStream encodes objects as one multi-document YAML stream.
+func encodeYAMLStream(objects ...interface{}) ([]byte, error) {
+	var docs [][]byte
+	for _, obj := range objects {
+		data, err := encodeYAML(obj)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, data)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// gitOpsFlavourName returns the name of flavour, or gitOpsNone for nil.
+func gitOpsFlavourName(flavour gitOpsFlavour) string {
+	if flavour == nil {
+		return gitOpsNone
+	}
+	return flavour.Name()
+}
+
+// renderGitOpsObjects returns the delivery objects of flavour for a tenant,
+// or nil for a nil flavour. It runs before the tenant directory is swapped in,
+// so a flavour that cannot point at the tenant fails the whole generation.
+func (g *Generator) renderGitOpsObjects(config *Config, flavour gitOpsFlavour) ([]byte, error) {
+	if flavour == nil {
+		return nil, nil
+	}
+	repoPath, err := g.repoPathFor(config)
+	if err != nil {
+		return nil, err
+	}
+	data, err := flavour.Render(config, repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s objects: %v", flavour.Name(), err)
+	}
+	return data, nil
+}
+
+// writeGitOpsObjects emits data, the delivery objects renderGitOpsObjects
+// returned for flavour, into the tenant's cluster directory and removes any
+// left over from another flavour. A nil flavour only cleans up.
+func (g *Generator) writeGitOpsObjects(config *Config, flavour gitOpsFlavour, data []byte) error {
+	current := gitOpsFlavourName(flavour)
+	if flavour != nil {
+		dir := g.gitOpsDirFor(config, current)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", dir, err)
+		}
+		path := g.gitOpsFileFor(config, current)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		g.logger.Printf("Wrote %s delivery objects %s", current, path)
+
+		if _, err := g.addKustomizationResource(dir, path); err != nil {
+			return fmt.Errorf("failed to register %s in %s: %v", path, dir, err)
+		}
+	}
+
+	for _, name := range gitOpsFlavourNames {
+		if name == current {
+			continue
+		}
+		if _, err := g.removeGitOpsObjects(config, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGitOpsObjects deletes a tenant's delivery objects for one flavour and
+// its entry in that flavour's cluster kustomization, if present. It reports
+// whether there was a file to remove.
+func (g *Generator) removeGitOpsObjects(config *Config, flavour string) (bool, error) {
+	path := g.gitOpsFileFor(config, flavour)
+	removed := false
+	if err := os.Remove(path); err == nil {
+		g.logger.Printf("Removed: %s", path)
+		removed = true
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove %s: %v", path, err)
+	}
+
+	if file := findKustomizationFile(g.gitOpsDirFor(config, flavour)); file != "" {
+		changed, err := g.removeKustomizationResource(file, path)
+		if err != nil {
+			return removed, fmt.Errorf("failed to update %s: %v", file, err)
+		}
+		if changed {
+			g.logger.Printf("Removed %s from %s", filepath.Base(path), file)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoPathFor(t *testing.T) {
+	abs := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relRoot, err := filepath.Rel(cwd, abs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "test", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+
+	tests := []struct {
+		name       string
+		outputRoot string
+		repoRoot   string
+		want       string
+		wantErr    bool
+	}{
+		{name: "both absolute", outputRoot: filepath.Join(abs, "environments"), repoRoot: abs, want: "./environments/dev/uksouth/aks1/ab12-test-web"},
+		{name: "absolute output, relative root", outputRoot: abs, repoRoot: relRoot, want: "./dev/uksouth/aks1/ab12-test-web"},
+		{name: "relative output, absolute root", outputRoot: relRoot, repoRoot: abs, want: "./dev/uksouth/aks1/ab12-test-web"},
+		{name: "output is the root", outputRoot: abs, repoRoot: abs, want: "./dev/uksouth/aks1/ab12-test-web"},
+		{name: "output outside the root", outputRoot: abs, repoRoot: filepath.Join(abs, "repo"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(Options{OutputRoot: tt.outputRoot, GitOpsRepoRoot: tt.repoRoot})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := g.repoPathFor(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("repoPathFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("repoPathFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTenantGitOpsFailureWritesNothing(t *testing.T) {
+	root := t.TempDir()
+	settings := "default: flux\nflux:\n  sourceName: platform\n  interval: 10m\n"
+	if err := os.WriteFile(filepath.Join(root, gitOpsSettingsFile), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The tenant is outside the repository, so no Flux path can be derived
+	g, err := New(Options{OutputRoot: root, GitOpsRepoRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	if err := g.AddOrModify(config); err == nil {
+		t.Fatal("AddOrModify() succeeded, want an error")
+	}
+	for _, path := range []string{g.tenantDir(config), filepath.Join(g.clusterDirFor(config), "kustomization.yaml")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a failed generation", path)
+		}
+	}
+}
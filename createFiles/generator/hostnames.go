@@ -0,0 +1,381 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scanHostnames returns the hostnames claimed by the routing objects in a
+// tenant directory.
+func scanHostnames(dir string) ([]string, error) {
+	files, err := readTenantFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return hostnamesInFiles(files)
+}
+
+// hostnamesInFiles returns the hostnames claimed by the routing objects in a
+// set of tenant files: Istio Gateway server hosts and VirtualService hosts,
+// and Gateway API listener hostnames and HTTPRoute hostnames. Istio's
+// "namespace/host" form is reduced to the host.
+func hostnamesInFiles(files map[string][]byte) ([]string, error) {
+	seen := make(map[string]bool)
+	for name, data := range files {
+		if filepath.Ext(name) != ".yaml" || strings.HasPrefix(name, "kustomization") {
+			continue
+		}
+		hosts, err := hostnamesInYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		for _, host := range hosts {
+			seen[strings.ToLower(host)] = true
+		}
+	}
+
+	hosts := make([]string, 0, len(seen))
+	for host := range seen {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// hostnamesInYAML collects the hostnames of every routing object in a YAML
+// stream.
+func hostnamesInYAML(data []byte) ([]string, error) {
+	var hosts []string
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj struct {
+			Kind string `yaml:"kind"`
+			Spec struct {
+				Servers []struct {
+					Hosts []string `yaml:"hosts"`
+				} `yaml:"servers"`
+				Listeners []struct {
+					Hostname string `yaml:"hostname"`
+				} `yaml:"listeners"`
+				Hosts     []string `yaml:"hosts"`
+				Hostnames []string `yaml:"hostnames"`
+			} `yaml:"spec"`
+		}
+		err := decoder.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return hosts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch obj.Kind {
+		case "Gateway":
+			for _, server := range obj.Spec.Servers {
+				for _, host := range server.Hosts {
+					if i := strings.Index(host, "/"); i >= 0 {
+						host = host[i+1:]
+					}
+					hosts = append(hosts, host)
+				}
+			}
+			for _, listener := range obj.Spec.Listeners {
+				if listener.Hostname != "" {
+					hosts = append(hosts, listener.Hostname)
+				}
+			}
+		case "VirtualService":
+			hosts = append(hosts, obj.Spec.Hosts...)
+		case "HTTPRoute":
+			hosts = append(hosts, obj.Spec.Hostnames...)
+		}
+	}
+}
+
+// hostnameOwnershipFile is the ConfigMap written into every cluster directory
+// mapping each claimed hostname to the namespace that owns it, for admission
+// policies to look up.
+const hostnameOwnershipFile = "hostname-ownership.yaml"
+
+// The ConfigMap holding the ownership map, in the namespace the admission
+// controller reads it from.
+const (
+	hostnameOwnershipName      = "hostname-ownership"
+	hostnameOwnershipNamespace = "kyverno"
+)
+
+type hostnameOwnershipConfigMap struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Data map[string]string `yaml:"data"`
+}
+
+// hostnameClaim is a hostname claimed by a tenant namespace.
+type hostnameClaim struct {
+	Host      string
+	Namespace string
+}
+
+// clusterHostnameClaims returns the hostnames claimed by every tenant in a
+// cluster directory except skip: the hosts of their routing objects and the
+// FullDomainName in their record. Tenants share the cluster's gateway, so this
+// is the set a new claim must not overlap with. A tenant whose files cannot be
+// parsed is an error.
+func (g *Generator) clusterHostnameClaims(clusterDir, skip string) ([]hostnameClaim, error) {
+	dirs, err := filepath.Glob(filepath.Join(clusterDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %v", clusterDir, err)
+	}
+
+	var claims []hostnameClaim
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		if strings.HasPrefix(name, ".") || isGitOpsFlavourDir(name) || filepath.Clean(dir) == filepath.Clean(skip) {
+			continue
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+
+		// A tenant whose claims cannot be read still owns them, so fail
+		// rather than let another tenant take its hosts
+		hosts, err := tenantHostnames(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the hostname claims of %s: %v", name, err)
+		}
+		for _, host := range hosts {
+			claims = append(claims, hostnameClaim{Host: host, Namespace: name})
+		}
+	}
+	return claims, nil
+}
+
+// tenantHostnames returns the hostnames a tenant directory claims, including
+// the FullDomainName recorded for it even when no routing object uses it.
+func tenantHostnames(dir string) ([]string, error) {
+	hosts, err := scanHostnames(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, tenantRecordFile)); err != nil {
+		return hosts, nil
+	}
+	record, err := readTenantRecord(dir)
+	if err != nil {
+		return nil, err
+	}
+	config, err := record.config()
+	if err != nil {
+		return nil, fmt.Errorf("tenant record: %v", err)
+	}
+	return addHostname(hosts, config.FullDomainName), nil
+}
+
+// addHostname adds host, lowercased, to a sorted list unless it is empty or
+// already there.
+func addHostname(hosts []string, host string) []string {
+	host = strings.ToLower(host)
+	if host == "" {
+		return hosts
+	}
+	i := sort.SearchStrings(hosts, host)
+	if i < len(hosts) && hosts[i] == host {
+		return hosts
+	}
+	return append(hosts[:i], append([]string{host}, hosts[i:]...)...)
+}
+
+// hostnamesOverlap reports whether two hostnames can match the same request:
+// they are equal, one is "*", or a wildcard "*.domain" covers a host or
+// wildcard below domain.
+func hostnamesOverlap(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b || a == "*" || b == "*" {
+		return true
+	}
+	aWild, bWild := strings.HasPrefix(a, "*."), strings.HasPrefix(b, "*.")
+	aDomain, bDomain := strings.TrimPrefix(a, "*."), strings.TrimPrefix(b, "*.")
+	switch {
+	case aWild && bWild:
+		return hostUnder(aDomain, bDomain) || hostUnder(bDomain, aDomain)
+	case aWild:
+		return b != aDomain && hostUnder(aDomain, b)
+	case bWild:
+		return a != bDomain && hostUnder(bDomain, a)
+	}
+	return false
+}
+
+// pendingTenant is a tenant that has passed the hostname claim check but is
+// not swapped into its cluster directory yet.
+type pendingTenant struct {
+	Namespace string
+	Hosts     []string
+}
+
+// reserveHostnames checks a tenant's hostnames and its cluster's capacity, and
+// holds both for the tenant until the returned release is called, once the
+// tenant is in its cluster directory or has failed. Only the check runs under
+// hostnameClaimsMu.
+func (g *Generator) reserveHostnames(config *Config, stage string) (func(), error) {
+	hosts, err := scanHostnames(stage)
+	if err != nil {
+		return nil, err
+	}
+	hosts = addHostname(hosts, config.FullDomainName)
+	clusterDir := g.clusterDirFor(config)
+	namespace := tenantNamespace(config)
+
+	g.hostnameClaimsMu.Lock()
+	defer g.hostnameClaimsMu.Unlock()
+	pending := g.pendingTenants[clusterDir]
+	if err := g.checkHostnameClaims(config, hosts, pending); err != nil {
+		return nil, err
+	}
+	if err := g.checkClusterCapacity(config, pending); err != nil {
+		return nil, err
+	}
+	g.pendingTenants[clusterDir] = append(pending, pendingTenant{Namespace: namespace, Hosts: hosts})
+
+	return func() {
+		g.hostnameClaimsMu.Lock()
+		defer g.hostnameClaimsMu.Unlock()
+		pending := g.pendingTenants[clusterDir]
+		for i, tenant := range pending {
+			if tenant.Namespace == namespace {
+				pending = append(pending[:i:i], pending[i+1:]...)
+				break
+			}
+		}
+		if len(pending) == 0 {
+			delete(g.pendingTenants, clusterDir)
+		} else {
+			g.pendingTenants[clusterDir] = pending
+		}
+	}, nil
+}
+
+// checkTenantClaims runs the checks of reserveHostnames on the hostnames in a
+// tenant's rendered files without reserving anything, so a plan fails where
+// the apply would.
+func (g *Generator) checkTenantClaims(config *Config, rendered map[string][]byte) error {
+	hosts, err := hostnamesInFiles(rendered)
+	if err != nil {
+		return err
+	}
+	hosts = addHostname(hosts, config.FullDomainName)
+
+	g.hostnameClaimsMu.Lock()
+	defer g.hostnameClaimsMu.Unlock()
+	pending := g.pendingTenants[g.clusterDirFor(config)]
+	if err := g.checkHostnameClaims(config, hosts, pending); err != nil {
+		return err
+	}
+	return g.checkClusterCapacity(config, pending)
+}
+
+// checkHostnameClaims rejects a tenant whose hostnames, taken from its
+// rendered files and its FullDomainName, duplicate or overlap a hostname
+// claimed by another tenant on the same cluster, on disk or still pending.
+// The caller holds hostnameClaimsMu.
+func (g *Generator) checkHostnameClaims(config *Config, hosts []string, pending []pendingTenant) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	claims, err := g.clusterHostnameClaims(g.clusterDirFor(config), g.tenantDir(config))
+	if err != nil {
+		return err
+	}
+	namespace := tenantNamespace(config)
+	for _, tenant := range pending {
+		if tenant.Namespace == namespace {
+			continue
+		}
+		for _, host := range tenant.Hosts {
+			claims = append(claims, hostnameClaim{Host: host, Namespace: tenant.Namespace})
+		}
+	}
+	var conflicts []string
+	for _, host := range hosts {
+		for _, claim := range claims {
+			if hostnamesOverlap(host, claim.Host) {
+				conflicts = append(conflicts, fmt.Sprintf("%s overlaps %s owned by %s", host, claim.Host, claim.Namespace))
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("hostname collision on cluster %s: %s", config.ClusterName, strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// hostnameOwnershipKey turns a hostname into a ConfigMap key. Keys cannot
+// hold '*', so a wildcard "*.example.com" is stored as "_.example.com";
+// hostnames never contain '_', so the key stays unambiguous.
+func hostnameOwnershipKey(host string) string {
+	return strings.Replace(host, "*", "_", 1)
+}
+
+// writeHostnameOwnership regenerates the ownership map of a cluster directory
+// from the tenants in it and lists it in the cluster kustomization. Hosts
+// claimed by more than one tenant, which only hand-made tenants can do, go to
+// the first namespace in name order and are logged. It reports whether the
+// file changed.
+func (g *Generator) writeHostnameOwnership(clusterDir string) (bool, error) {
+	if _, err := os.Stat(clusterDir); os.IsNotExist(err) {
+		return false, nil
+	}
+	claims, err := g.clusterHostnameClaims(clusterDir, "")
+	if err != nil {
+		return false, err
+	}
+	owners := make(map[string]string)
+	for _, claim := range claims {
+		key := hostnameOwnershipKey(claim.Host)
+		if owner, ok := owners[key]; ok && owner != claim.Namespace {
+			g.logger.Printf("Warning: %s is claimed by both %s and %s", claim.Host, owner, claim.Namespace)
+			continue
+		}
+		owners[key] = claim.Namespace
+	}
+
+	configMap := hostnameOwnershipConfigMap{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Data:       owners,
+	}
+	configMap.Metadata.Name = hostnameOwnershipName
+	configMap.Metadata.Namespace = hostnameOwnershipNamespace
+	configMap.Metadata.Labels = map[string]string{"app.kubernetes.io/managed-by": "createFiles"}
+	body, err := encodeYAML(configMap)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode hostname ownership: %v", err)
+	}
+	data := append([]byte("# Generated by createFiles from the tenants in this cluster directory, do not edit.\n"), body...)
+
+	path := filepath.Join(clusterDir, hostnameOwnershipFile)
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if _, err := g.addKustomizationResource(clusterDir, path); err != nil {
+		return false, fmt.Errorf("failed to register %s: %v", path, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,169 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParallelTenantsCannotClaimTheSameHost(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const tenants = 8
+	errs := make([]error, tenants)
+	var wg sync.WaitGroup
+	for i := 0; i < tenants; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = g.AddOrModify(&Config{
+				OpEnvironment:  "dev",
+				Region:         "uksouth",
+				ClusterName:    "aks1",
+				Swci:           "ab12",
+				Suffix:         fmt.Sprintf("web%d", i),
+				FullDomainName: "web.apps.example.com",
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d tenants claimed the same host, want 1: %v", succeeded, errs)
+	}
+	if len(g.pendingTenants) != 0 {
+		t.Errorf("pending tenants left after generation: %v", g.pendingTenants)
+	}
+}
+
+func TestHostnamesOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "web.example.com", b: "web.example.com", want: true},
+		{a: "WEB.example.com", b: "web.EXAMPLE.com", want: true},
+		{a: "web.example.com", b: "api.example.com", want: false},
+		{a: "*", b: "web.example.com", want: true},
+		{a: "web.example.com", b: "*", want: true},
+		{a: "*.example.com", b: "web.example.com", want: true},
+		{a: "web.example.com", b: "*.example.com", want: true},
+		{a: "*.example.com", b: "a.b.example.com", want: true},
+		{a: "*.example.com", b: "example.com", want: false},
+		{a: "example.com", b: "*.example.com", want: false},
+		{a: "*.example.com", b: "webexample.com", want: false},
+		{a: "*.example.com", b: "*.apps.example.com", want: true},
+		{a: "*.apps.example.com", b: "*.example.com", want: true},
+		{a: "*.example.com", b: "*.example.org", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := hostnamesOverlap(tt.a, tt.b); got != tt.want {
+				t.Errorf("hostnamesOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostnameOwnershipKey(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "web.example.com", want: "web.example.com"},
+		{host: "*.example.com", want: "_.example.com"},
+		{host: "*", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := hostnameOwnershipKey(tt.host); got != tt.want {
+				t.Errorf("hostnameOwnershipKey(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnreadableTenantKeepsItsHostnames(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web", FullDomainName: "web.apps.example.com"}
+	if err := g.AddOrModify(owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(g.tenantDir(owner), "notes.yaml"), []byte("kind: [unclosed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	claimant := *owner
+	claimant.Suffix = "other"
+	err = g.AddOrModify(&claimant)
+	if err == nil || !strings.Contains(err.Error(), owner.Swci+"-dev-web") {
+		t.Fatalf("AddOrModify() error = %v, want the unreadable tenant named", err)
+	}
+	if _, err := os.Stat(g.tenantDir(&claimant)); !os.IsNotExist(err) {
+		t.Errorf("%s was generated over an unreadable claim", g.tenantDir(&claimant))
+	}
+}
+
+func TestPlanChecksClusterClaims(t *testing.T) {
+	root := t.TempDir()
+	catalog := "clusters:\n  - name: aks1\n    region: uksouth\n    environments: [dev]\n    capacity:\n      maxTenants: 2\n"
+	if err := os.WriteFile(filepath.Join(root, clusterCatalogFile), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"web", "api"} {
+		config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: suffix, FullDomainName: suffix + ".apps.example.com"}
+		if err := g.AddOrModify(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{
+			name:   "existing tenant",
+			config: Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web", FullDomainName: "web.apps.example.com"},
+		},
+		{
+			name:    "hostname collision",
+			config:  Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "api", FullDomainName: "web.apps.example.com"},
+			wantErr: "hostname collision",
+		},
+		{
+			name:    "full cluster",
+			config:  Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "new"},
+			wantErr: "is full",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := g.Plan(&tt.config)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Plan() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
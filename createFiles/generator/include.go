@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// includeDirective marks a header comment in an overlay template that holds an
+// include condition, e.g. "# include-when: FullDomainName set". A template may
+// declare several; it is rendered only when all of them hold.
+const includeDirective = "include-when:"
+
+// legacyIncludeConditions covers templates that predate include-when headers.
+// They apply only when the template declares no conditions of its own.
+var legacyIncludeConditions = map[string][]string{
+	"gateway.yaml": {"FullDomainName set"},
+	"app.yaml":     {"Suffix contains ob-test"},
+}
+
+// headerComments returns the text of the comment lines at the top of a YAML
+// file, before the first content line.
+func headerComments(r io.Reader) ([]string, error) {
+	var comments []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == "---" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		comments = append(comments, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+	}
+	return comments, scanner.Err()
+}
+
+// templateIncludeConditions reads the include-when directives from the leading
+// comment block of the template name in templates.
+func templateIncludeConditions(templates fs.FS, name string) ([]condition, error) {
+	f, err := templates.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+
+	comments, err := headerComments(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	var conditions []condition
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment, includeDirective) {
+			continue
+		}
+		cond, err := parseCondition(strings.TrimPrefix(comment, includeDirective))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		conditions = append(conditions, cond)
+	}
+	return conditions, nil
+}
+
+// includeTemplate decides whether the template name in templates is rendered
+// for config and its parsed repository, and returns the reason for logging.
+func includeTemplate(templates fs.FS, name string, config *Config, repo *repoSource) (bool, string, error) {
+	conditions, err := templateIncludeConditions(templates, name)
+	if err != nil {
+		return false, "", err
+	}
+	if len(conditions) == 0 {
+		for _, expr := range legacyIncludeConditions[name] {
+			cond, err := parseCondition(expr)
+			if err != nil {
+				return false, "", err
+			}
+			conditions = append(conditions, cond)
+		}
+	}
+	if len(conditions) == 0 {
+		return true, "no include conditions", nil
+	}
+
+	var reasons []string
+	for _, cond := range conditions {
+		if !cond.matches(config, repo) {
+			return false, cond.explain(config, repo) + " is false", nil
+		}
+		reasons = append(reasons, cond.explain(config, repo))
+	}
+	return true, strings.Join(reasons, ", "), nil
+}
@@ -0,0 +1,236 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Inventory output formats.
+const (
+	inventoryTable = "table"
+	inventoryJSON  = "json"
+)
+
+// inventoryTenant is a tenant directory found by the inventory, described by
+// its record when it has one and by its path otherwise.
+type inventoryTenant struct {
+	Summary TenantSummary
+	Config  *Config
+}
+
+// inventorySortKeys are the columns the inventory can be sorted by.
+var inventorySortKeys = map[string]func(s *TenantSummary) string{
+	"namespace":   func(s *TenantSummary) string { return s.Namespace },
+	"environment": func(s *TenantSummary) string { return s.Environment },
+	"region":      func(s *TenantSummary) string { return s.Region },
+	"cluster":     func(s *TenantSummary) string { return s.Cluster },
+	"swci":        func(s *TenantSummary) string { return s.Swci },
+	"suffix":      func(s *TenantSummary) string { return s.Suffix },
+	"variant":     func(s *TenantSummary) string { return s.Variant },
+	"domain":      func(s *TenantSummary) string { return s.Domain },
+	"repo":        func(s *TenantSummary) string { return s.RepoURL },
+	"generated": func(s *TenantSummary) string {
+		if s.GeneratedAt == nil {
+			return ""
+		}
+		return s.GeneratedAt.UTC().Format(time.RFC3339Nano)
+	},
+}
+
+// scanInventory describes every tenant discoverTenants finds under root.
+// Directories without a tenant record, e.g. created by hand or before records
+// existed, are described with the inputs legacyTenantConfig recovers, or from
+// their path and files when it cannot; tenants with an unreadable record are
+// logged and skipped.
+func (g *Generator) scanInventory(root string) ([]inventoryTenant, error) {
+	discovered, err := g.discoverTenants(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenants []inventoryTenant
+	for _, tenant := range discovered {
+		if tenant.Err != nil && !tenant.Legacy {
+			g.logger.Printf("Skipping %s: %v", tenant.Dir, tenant.Err)
+			continue
+		}
+		summary, err := g.describeTenant(tenant)
+		if err != nil {
+			g.logger.Printf("Skipping %s: %v", tenant.Dir, err)
+			continue
+		}
+		tenants = append(tenants, inventoryTenant{Summary: summary, Config: tenant.Config})
+	}
+	return tenants, nil
+}
+
+// isGitOpsFlavourDir reports whether name is a delivery object directory in a
+// cluster directory rather than a tenant.
+func isGitOpsFlavourDir(name string) bool {
+	for _, flavour := range gitOpsFlavourNames {
+		if name == flavour {
+			return true
+		}
+	}
+	return false
+}
+
+// describeTenant builds the inventory entry for one discovered tenant.
+func (g *Generator) describeTenant(tenant discoveredTenant) (TenantSummary, error) {
+	config := tenant.Config
+	summary := TenantSummary{
+		Namespace:   tenantNamespace(config),
+		Dir:         tenant.Dir,
+		Environment: config.OpEnvironment,
+		Region:      config.Region,
+		Cluster:     config.ClusterName,
+		Swci:        config.Swci,
+		Suffix:      config.Suffix,
+		Domain:      config.FullDomainName,
+		RepoURL:     config.GitLabRepoURL,
+		RepoKind:    repoSourceFor(config, g.opts.RepoHostKinds).Kind,
+		Managed:     !tenant.Legacy,
+	}
+	switch {
+	case !tenant.Legacy:
+		summary.Variant = tenant.Record.Variant
+		summary.TemplateVersion = tenant.Record.templateVersion()
+		summary.GitOps = tenant.Record.GitOps
+		generated := tenant.Record.GeneratedAt
+		summary.GeneratedAt = &generated
+	case tenant.Err != nil:
+		// Hand-made tenants only reveal their domains through their routing
+		hosts, err := scanHostnames(tenant.Dir)
+		if err != nil {
+			return TenantSummary{}, err
+		}
+		summary.Domain = strings.Join(hosts, ",")
+	}
+	return summary, nil
+}
+
+// configFromTenantPath recovers the tenant inputs encoded in a directory path
+// and its <swci>-<env>-<suffix> name. The environment is the part of the name
+// that maps onto the environment directory.
+func (g *Generator) configFromTenantPath(root, dir string) (*Config, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("not an <env>/<region>/<cluster>/<namespace> directory")
+	}
+	envDir, region, cluster, name := parts[0], parts[1], parts[2], parts[3]
+
+	for _, env := range allowedEnvironments {
+		if g.envDirFor(env) != envDir {
+			continue
+		}
+		i := strings.Index(name, "-"+env+"-")
+		if i <= 0 || i+len(env)+2 >= len(name) {
+			continue
+		}
+		return &Config{
+			OpEnvironment: env,
+			Region:        region,
+			ClusterName:   cluster,
+			Swci:          name[:i],
+			Suffix:        name[i+len(env)+2:],
+		}, nil
+	}
+	return nil, fmt.Errorf("no %s and name is not <swci>-<env>-<suffix> for environment directory %s", tenantRecordFile, envDir)
+}
+
+// sortInventory orders summaries by key, then by directory.
+func sortInventory(summaries []TenantSummary, key string) error {
+	if key == "" {
+		key = "namespace"
+	}
+	field, ok := inventorySortKeys[key]
+	if !ok {
+		keys := make([]string, 0, len(inventorySortKeys))
+		for k := range inventorySortKeys {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("unknown sort key %q, use one of %s", key, strings.Join(keys, ", "))
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := field(&summaries[i]), field(&summaries[j])
+		if a != b {
+			return a < b
+		}
+		return summaries[i].Dir < summaries[j].Dir
+	})
+	return nil
+}
+
+// printInventory writes summaries as an aligned table or as JSON.
+func printInventory(w io.Writer, summaries []TenantSummary, format string) error {
+	switch format {
+	case inventoryJSON:
+		if summaries == nil {
+			summaries = []TenantSummary{}
+		}
+		data, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case inventoryTable, "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAMESPACE\tENV\tREGION\tCLUSTER\tSWCI\tSUFFIX\tVARIANT\tSET\tDOMAIN\tSOURCE")
+		for _, s := range summaries {
+			variant := s.Variant
+			if !s.Managed {
+				variant = "(unmanaged)"
+			}
+			source := "-"
+			if s.RepoURL != "" {
+				source = s.RepoKind + ":" + s.RepoURL
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Namespace, s.Environment, s.Region, s.Cluster,
+				s.Swci, s.Suffix, variant, dashIfEmpty(s.TemplateVersion), dashIfEmpty(s.Domain), source)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d tenants\n", len(summaries))
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, use %s or %s", format, inventoryTable, inventoryJSON)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Inventory writes the tenants under OutputRoot matching filter to w, sorted
+// by sortBy, as a table or JSON.
+func (g *Generator) Inventory(w io.Writer, filter TenantFilter, sortBy, format string) error {
+	tenants, err := g.scanInventory(g.opts.OutputRoot)
+	if err != nil {
+		return err
+	}
+	var summaries []TenantSummary
+	for _, tenant := range tenants {
+		if filter.matches(tenant.Config, g.envDirFor(tenant.Config.OpEnvironment)) {
+			summaries = append(summaries, tenant.Summary)
+		}
+	}
+	if err := sortInventory(summaries, sortBy); err != nil {
+		return err
+	}
+	return printInventory(w, summaries, format)
+}
@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInventoryDescribesLegacyTenants(t *testing.T) {
+	root := t.TempDir()
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	dir := g.tenantDir(legacy)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	kustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\ncommonAnnotations:\n  " +
+		legacySourceRepoAnnotation + ": https://gitlab.com/team/web.git\n"
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory named like a tenant but without a kustomization is still listed
+	bare := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "api"}
+	if err := os.MkdirAll(g.tenantDir(bare), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := g.Inventory(&out, TenantFilter{}, "", inventoryJSON); err != nil {
+		t.Fatal(err)
+	}
+	var summaries []TenantSummary
+	if err := json.Unmarshal(out.Bytes(), &summaries); err != nil {
+		t.Fatalf("Inventory() output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("Inventory() listed %d tenants, want 2:\n%s", len(summaries), out.String())
+	}
+	api, web := summaries[0], summaries[1]
+	if api.Namespace != "ab12-dev-api" || api.Managed {
+		t.Errorf("bare directory summary = %+v, want unmanaged ab12-dev-api", api)
+	}
+	if web.Managed || web.RepoURL != "https://gitlab.com/team/web.git" || web.RepoKind != repoKindGitLab {
+		t.Errorf("legacy tenant summary = %+v, want unmanaged with the source-repo annotation as a gitlab repo", web)
+	}
+}
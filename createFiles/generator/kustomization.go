@@ -0,0 +1,198 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kustomizationFileNames are the file names kustomize accepts, in the order it
+// looks for them.
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// findKustomizationFile returns the kustomization file in dir, or an empty
+// string if the directory has none.
+func findKustomizationFile(dir string) string {
+	for _, name := range kustomizationFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// readKustomizationNode parses a kustomization file into a yaml.Node so it can
+// be edited without losing comments or unrelated fields.
+func readKustomizationNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a kustomization mapping", path)
+	}
+	return &doc, nil
+}
+
+// encodeYAML marshals v with the two-space indentation used in the overlays.
+func encodeYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeKustomizationNode writes an edited kustomization document back to disk.
+func writeKustomizationNode(path string, doc *yaml.Node) error {
+	data, err := encodeYAML(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// mappingValue returns the value node stored under key in a mapping node.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKustomizationResource drops every resources entry in the kustomization
+// file at path that points at target. It reports whether the file changed.
+func (g *Generator) removeKustomizationResource(path, target string) (bool, error) {
+	g.parentKustomizationMu.Lock()
+	defer g.parentKustomizationMu.Unlock()
+
+	doc, err := readKustomizationNode(path)
+	if err != nil {
+		return false, err
+	}
+
+	resources := mappingValue(doc.Content[0], "resources")
+	if resources == nil || resources.Kind != yaml.SequenceNode {
+		return false, nil
+	}
+
+	base := filepath.Dir(path)
+	target = filepath.Clean(target)
+	kept := resources.Content[:0]
+	removed := false
+	for _, item := range resources.Content {
+		if item.Kind == yaml.ScalarNode && filepath.Clean(filepath.Join(base, item.Value)) == target {
+			removed = true
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if !removed {
+		return false, nil
+	}
+	resources.Content = kept
+	normaliseResources(resources)
+
+	if err := writeKustomizationNode(path, doc); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// addKustomizationResource lists target in the resources of the kustomization
+// in dir, creating the file if there is none. The list is kept sorted and free
+// of duplicates; comments and other fields are preserved. It reports whether
+// the file changed.
+func (g *Generator) addKustomizationResource(dir, target string) (bool, error) {
+	g.parentKustomizationMu.Lock()
+	defer g.parentKustomizationMu.Unlock()
+
+	entry, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve %s relative to %s: %v", target, dir, err)
+	}
+	entry = filepath.ToSlash(entry)
+
+	path := findKustomizationFile(dir)
+	if path == "" {
+		path = filepath.Join(dir, "kustomization.yaml")
+		data := fmt.Sprintf("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - %s\n", entry)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			return false, fmt.Errorf("failed to create %s: %v", path, err)
+		}
+		return true, nil
+	}
+
+	doc, err := readKustomizationNode(path)
+	if err != nil {
+		return false, err
+	}
+	before, err := encodeYAML(doc)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+
+	root := doc.Content[0]
+	resources := mappingValue(root, "resources")
+	if resources == nil {
+		resources = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "resources"}, resources)
+	}
+	if resources.Kind != yaml.SequenceNode {
+		return false, fmt.Errorf("%s: resources is not a list", path)
+	}
+	resources.Style = 0
+	resources.Content = append(resources.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry})
+	normaliseResources(resources)
+
+	after, err := encodeYAML(doc)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+	if err := os.WriteFile(path, after, 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// normaliseResources sorts a resources sequence and drops entries that point
+// at the same path. Comments travel with their entries.
+func normaliseResources(resources *yaml.Node) {
+	key := func(n *yaml.Node) string { return filepath.ToSlash(filepath.Clean(n.Value)) }
+
+	sort.SliceStable(resources.Content, func(i, j int) bool {
+		return key(resources.Content[i]) < key(resources.Content[j])
+	})
+
+	seen := make(map[string]bool)
+	kept := resources.Content[:0]
+	for _, item := range resources.Content {
+		if item.Kind == yaml.ScalarNode {
+			if seen[key(item)] {
+				continue
+			}
+			seen[key(item)] = true
+		}
+		kept = append(kept, item)
+	}
+	resources.Content = kept
+}
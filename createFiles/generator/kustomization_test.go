@@ -0,0 +1,44 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNormaliseResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources string
+		want      []string
+	}{
+		{name: "empty", resources: "[]", want: nil},
+		{name: "sorted", resources: "[b, a, c]", want: []string{"a", "b", "c"}},
+		{name: "same path kept once", resources: "[b, ./a, a]", want: []string{"./a", "b"}},
+		{name: "trailing slash", resources: "[c/, c, ../x]", want: []string{"../x", "c/"}},
+		{name: "comments travel", resources: "- b # bee\n- a # ay\n", want: []string{"a # ay", "b # bee"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.resources), &doc); err != nil {
+				t.Fatal(err)
+			}
+			resources := doc.Content[0]
+			normaliseResources(resources)
+
+			var got []string
+			for _, n := range resources.Content {
+				entry := n.Value
+				if n.LineComment != "" {
+					entry += " " + n.LineComment
+				}
+				got = append(got, entry)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normaliseResources() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
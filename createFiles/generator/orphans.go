@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// userOwnedDirective in a file's header comments tells createFiles the file
+// is maintained by hand and must never be removed as an orphan.
+const userOwnedDirective = "createFiles:user-owned"
+
+// Orphan handling modes, see Options.OrphanMode.
+const (
+	OrphansRemove = "remove"
+	OrphansReport = "report"
+)
+
+// isUserOwned reports whether data carries the user-owned marker.
+func isUserOwned(data []byte) bool {
+	comments, _ := headerComments(bytes.NewReader(data))
+	for _, comment := range comments {
+		if comment == userOwnedDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// findOrphans returns the YAML files in current that are not in expected,
+// sorted by name. Only managed files are orphans; the others were put there
+// by hand and are returned as unmanaged, to be warned about but never
+// removed. Files marked as user-owned are neither, and templates only ever
+// produce YAML, so anything else is left out too.
+func findOrphans(current map[string][]byte, expected, managed map[string]bool) (orphans, unmanaged []string) {
+	for name, data := range current {
+		if filepath.Ext(name) != ".yaml" || expected[name] || isUserOwned(data) {
+			continue
+		}
+		if managed[name] {
+			orphans = append(orphans, name)
+		} else {
+			unmanaged = append(unmanaged, name)
+		}
+	}
+	sort.Strings(orphans)
+	sort.Strings(unmanaged)
+	return orphans, unmanaged
+}
+
+// readPreviousRecord returns the tenant record in dir, or nil when there is
+// none.
+func readPreviousRecord(dir string) (*tenantRecord, error) {
+	if _, err := os.Stat(filepath.Join(dir, tenantRecordFile)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return readTenantRecord(dir)
+}
+
+// managedFiles returns the file names createFiles generated into a tenant
+// directory: those its previous record lists and every file the template set
+// it was pinned to can produce, so files left from before the tenant had a
+// record are recognised too. Without a record the tenant is on
+// legacyTemplateVersion.
+func (g *Generator) managedFiles(previous *tenantRecord) (map[string]bool, error) {
+	version := legacyTemplateVersion
+	if previous != nil {
+		version = previous.templateVersion()
+	}
+	names, err := g.templateOutputNames(version)
+	if err != nil {
+		return nil, err
+	}
+	for name := range previous.generatedFiles() {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// templateOutputNames returns every file name a template set can render to:
+// kustomization.yaml for the variants and the overlay templates' own names.
+func (g *Generator) templateOutputNames(version string) (map[string]bool, error) {
+	sources, err := g.effectiveTemplates(version)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{"kustomization.yaml": true}
+	for _, src := range sources {
+		if src.Name == variantRulesFile || strings.HasPrefix(src.Name, "kustomization") {
+			continue
+		}
+		variantSource, err := g.isVariantSource(version, src.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !variantSource {
+			names[src.Name] = true
+		}
+	}
+	return names, nil
+}
+
+// pruneOrphans handles the files in dir that files no longer produce: orphans
+// are removed or reported according to OrphanMode, unmanaged files are only
+// reported.
+func (g *Generator) pruneOrphans(dir string, files []templateFile) error {
+	current, err := readTenantFiles(dir)
+	if err != nil {
+		return err
+	}
+	previous, err := readPreviousRecord(dir)
+	if err != nil {
+		return err
+	}
+	expected := make(map[string]bool, len(files))
+	for _, file := range files {
+		expected[file.Dest] = true
+	}
+
+	managed, err := g.managedFiles(previous)
+	if err != nil {
+		return err
+	}
+	orphans, unmanaged := findOrphans(current, expected, managed)
+	for _, name := range unmanaged {
+		g.logger.Printf("Warning: %s was not generated by createFiles, leaving it in place", name)
+	}
+	for _, name := range orphans {
+		if g.opts.OrphanMode == OrphansReport {
+			g.logger.Printf("Orphaned file %s is no longer generated (left in place, orphan mode %s)", name, g.opts.OrphanMode)
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to remove orphaned file %s: %v", name, err)
+		}
+		g.logger.Printf("Removed orphaned file %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindOrphans(t *testing.T) {
+	current := map[string][]byte{
+		"kustomization.yaml": []byte("kind: Kustomization\n"),
+		"gateway.yaml":       []byte("kind: Gateway\n"),
+		"extra.yaml":         []byte("kind: ConfigMap\n"),
+		"owned.yaml":         []byte("# createFiles:user-owned\nkind: ConfigMap\n"),
+		"README.md":          []byte("notes\n"),
+	}
+	expected := map[string]bool{"kustomization.yaml": true}
+
+	tests := []struct {
+		name          string
+		managed       map[string]bool
+		wantOrphans   []string
+		wantUnmanaged []string
+	}{
+		{
+			name:          "nothing managed",
+			managed:       nil,
+			wantUnmanaged: []string{"extra.yaml", "gateway.yaml"},
+		},
+		{
+			name:          "generated file",
+			managed:       map[string]bool{"kustomization.yaml": true, "gateway.yaml": true},
+			wantOrphans:   []string{"gateway.yaml"},
+			wantUnmanaged: []string{"extra.yaml"},
+		},
+		{
+			name:        "user-owned file is managed",
+			managed:     map[string]bool{"gateway.yaml": true, "extra.yaml": true, "owned.yaml": true},
+			wantOrphans: []string{"extra.yaml", "gateway.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orphans, unmanaged := findOrphans(current, expected, tt.managed)
+			if !reflect.DeepEqual(orphans, tt.wantOrphans) {
+				t.Errorf("orphans = %v, want %v", orphans, tt.wantOrphans)
+			}
+			if !reflect.DeepEqual(unmanaged, tt.wantUnmanaged) {
+				t.Errorf("unmanaged = %v, want %v", unmanaged, tt.wantUnmanaged)
+			}
+		})
+	}
+}
+
+func TestLegacyTenantLeftoversAreRemoved(t *testing.T) {
+	root := t.TempDir()
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web", FullDomainName: "web.apps.example.com"}
+	writeLegacyTenant(t, root, config)
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := g.tenantDir(config)
+	if _, err := os.Stat(filepath.Join(dir, "gateway.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.yaml"), []byte("kind: ConfigMap\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a domain the legacy set no longer renders gateway.yaml
+	config.FullDomainName = ""
+	if err := g.AddOrModify(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gateway.yaml")); !os.IsNotExist(err) {
+		t.Errorf("gateway.yaml left over from the legacy set was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.yaml")); err != nil {
+		t.Errorf("hand-made notes.yaml was removed: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package generator
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// templateLayer is one file system of a template overlay.
+type templateLayer struct {
+	fsys fs.FS
+	// dir is the override directory the layer reads, empty for the embedded
+	// templates.
+	dir string
+}
+
+// templateOverlay is the file system a template set is rendered from: the
+// embedded set with the override directories stacked on top, where a file in
+// a later layer replaces the file of the same name below it. Template sets
+// are flat, so only the top directory is merged. Layers that do not exist,
+// such as a version with no override directory, are empty.
+type templateOverlay struct {
+	layers []templateLayer
+}
+
+// layerOf returns the top-most layer holding name.
+func (o *templateOverlay) layerOf(name string) (*templateLayer, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for i := len(o.layers) - 1; i >= 0; i-- {
+		_, err := fs.Stat(o.layers[i].fsys, name)
+		if err == nil {
+			return &o.layers[i], nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Open opens the top-most copy of name, or the merged top directory for ".".
+func (o *templateOverlay) Open(name string) (fs.File, error) {
+	if name == "." {
+		entries, err := o.ReadDir(".")
+		if err != nil {
+			return nil, err
+		}
+		return &overlayDir{entries: entries}, nil
+	}
+	layer, err := o.layerOf(name)
+	if err != nil {
+		return nil, err
+	}
+	return layer.fsys.Open(name)
+}
+
+// ReadDir lists the merged top directory sorted by name, or a directory of
+// the top-most layer holding it.
+func (o *templateOverlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		layer, err := o.layerOf(name)
+		if err != nil {
+			return nil, err
+		}
+		return fs.ReadDir(layer.fsys, name)
+	}
+
+	byName := make(map[string]fs.DirEntry)
+	for _, layer := range o.layers {
+		entries, err := fs.ReadDir(layer.fsys, ".")
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			byName[entry.Name()] = entry
+		}
+	}
+	entries := make([]fs.DirEntry, 0, len(byName))
+	for _, entry := range byName {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// overlayDir is the merged top directory of a templateOverlay opened as a
+// file.
+type overlayDir struct {
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *overlayDir) Stat() (fs.FileInfo, error) { return overlayDirInfo{}, nil }
+func (d *overlayDir) Close() error               { return nil }
+
+func (d *overlayDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
+
+// overlayDirInfo describes the merged top directory.
+type overlayDirInfo struct{}
+
+func (overlayDirInfo) Name() string       { return "." }
+func (overlayDirInfo) Size() int64        { return 0 }
+func (overlayDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (overlayDirInfo) ModTime() time.Time { return time.Time{} }
+func (overlayDirInfo) IsDir() bool        { return true }
+func (overlayDirInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplateOverlay(t *testing.T) {
+	templateDir := t.TempDir()
+	overrides := filepath.Join(templateDir, "v2")
+	if err := os.Mkdir(overrides, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"app.yaml": "kind: Override\n", "extra.yaml": "kind: Extra\n"} {
+		if err := os.WriteFile(filepath.Join(overrides, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := New(Options{OutputRoot: t.TempDir(), TemplateDir: templateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := g.templateFS("v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(overlay, "app.yaml", "extra.yaml", "namespace.yaml", "kustomization.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		want        string
+		wantOrigin  string
+		wantShadows bool
+	}{
+		{name: "app.yaml", want: "kind: Override\n", wantOrigin: filepath.Join(overrides, "app.yaml"), wantShadows: true},
+		{name: "extra.yaml", want: "kind: Extra\n", wantOrigin: filepath.Join(overrides, "extra.yaml")},
+		{name: "namespace.yaml", wantOrigin: templateOriginEmbedded},
+	}
+	sources, err := g.effectiveTemplates("v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]templateSource)
+	for _, src := range sources {
+		byName[src.Name] = src
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("%s is not in effect", tt.name)
+			}
+			if src.Origin != tt.wantOrigin || src.Shadows != tt.wantShadows {
+				t.Errorf("origin = %s shadows %v, want %s shadows %v", src.Origin, src.Shadows, tt.wantOrigin, tt.wantShadows)
+			}
+			data, err := fs.ReadFile(overlay, tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" && string(data) != tt.want {
+				t.Errorf("ReadFile(%s) = %q, want %q", tt.name, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanWritesNothing(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+	root := t.TempDir()
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	if _, err := g.Plan(config); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{cache, root} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Plan() wrote %d entries to %s", len(entries), dir)
+		}
+	}
+}
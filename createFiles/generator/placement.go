@@ -0,0 +1,264 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// placementTarget is one cluster a tenant is placed on.
+type placementTarget struct {
+	Region  string
+	Cluster string
+}
+
+// placementSelectorKeys are the catalog fields a placement selector can
+// match, besides environment which is always the tenant's.
+var placementSelectorKeys = map[string]func(catalog *clusterCatalog, c *catalogCluster) string{
+	"region":     func(_ *clusterCatalog, c *catalogCluster) string { return c.Region },
+	"regionPair": func(catalog *clusterCatalog, c *catalogCluster) string { return catalog.regionPairOf(c.Region) },
+	"routing":    func(_ *clusterCatalog, c *catalogCluster) string { return c.Routing },
+	"cni":        func(_ *clusterCatalog, c *catalogCluster) string { return c.CNI },
+	"gitops":     func(_ *clusterCatalog, c *catalogCluster) string { return c.GitOps },
+	"spot":       func(_ *clusterCatalog, c *catalogCluster) string { return strconv.FormatBool(c.Spot) },
+}
+
+// resolvePlacement turns a placement into the clusters config is placed on.
+// A placement is either a comma-separated list of region/cluster pairs,
+// "uksouth/aks-uks-prod-01,ukwest/aks-ukw-prod-01", or a selector of
+// key=value terms matched against the cluster catalog,
+// "regionPair=uk,spot=false", which picks every cluster hosting the tenant's
+// environment that matches all terms. Targets come back sorted.
+func (g *Generator) resolvePlacement(config *Config, placement string) ([]placementTarget, error) {
+	if strings.TrimSpace(placement) == "" {
+		return nil, fmt.Errorf("empty placement")
+	}
+	terms := strings.Split(placement, ",")
+	for i := range terms {
+		terms[i] = strings.TrimSpace(terms[i])
+	}
+
+	var targets []placementTarget
+	var err error
+	if strings.Contains(terms[0], "=") {
+		targets, err = g.selectPlacement(config, terms)
+	} else {
+		targets, err = listPlacement(terms)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("placement %q: %v", placement, err)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Region != targets[j].Region {
+			return targets[i].Region < targets[j].Region
+		}
+		return targets[i].Cluster < targets[j].Cluster
+	})
+	return targets, nil
+}
+
+// listPlacement parses region/cluster pairs, rejecting repeats.
+func listPlacement(terms []string) ([]placementTarget, error) {
+	var targets []placementTarget
+	seen := make(map[placementTarget]bool)
+	for _, term := range terms {
+		parts := strings.Split(term, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%q is not region/cluster", term)
+		}
+		target := placementTarget{Region: parts[0], Cluster: parts[1]}
+		if seen[target] {
+			return nil, fmt.Errorf("%s is listed twice", term)
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// selectPlacement picks the catalog clusters hosting config's environment that
+// match every key=value term.
+func (g *Generator) selectPlacement(config *Config, terms []string) ([]placementTarget, error) {
+	catalog, err := g.currentClusterCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if catalog == nil {
+		return nil, fmt.Errorf("selectors need a %s cluster catalog", clusterCatalogFile)
+	}
+
+	want := make(map[string]string)
+	for _, term := range terms {
+		key, value, ok := strings.Cut(term, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%q is not key=value", term)
+		}
+		if _, known := placementSelectorKeys[key]; !known {
+			keys := make([]string, 0, len(placementSelectorKeys))
+			for k := range placementSelectorKeys {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return nil, fmt.Errorf("unknown selector key %q, use one of %s", key, strings.Join(keys, ", "))
+		}
+		if key == "regionPair" && catalog.RegionPairs[value] == nil {
+			return nil, fmt.Errorf("unknown region pair %q", value)
+		}
+		want[key] = value
+	}
+
+	var targets []placementTarget
+	for i := range catalog.Clusters {
+		cluster := &catalog.Clusters[i]
+		if !cluster.hosts(config.OpEnvironment) {
+			continue
+		}
+		matched := true
+		for key, value := range want {
+			if placementSelectorKeys[key](catalog, cluster) != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			targets = append(targets, placementTarget{Region: cluster.Region, Cluster: cluster.Name})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no %s cluster in the catalog matches", config.OpEnvironment)
+	}
+	return targets, nil
+}
+
+// placedConfigs returns a copy of config for every target.
+func placedConfigs(config *Config, targets []placementTarget) []Config {
+	configs := make([]Config, len(targets))
+	for i, target := range targets {
+		configs[i] = *config
+		configs[i].Region = target.Region
+		configs[i].ClusterName = target.Cluster
+	}
+	return configs
+}
+
+// placementVersion returns the template set every replica of a placed tenant
+// is rendered with, so the tenant directories stay consistent: the set the
+// existing replicas are pinned to, or the default for a new tenant. Replicas
+// pinned to different sets must be upgraded first.
+func (g *Generator) placementVersion(configs []Config) (string, error) {
+	version := ""
+	pinnedBy := ""
+	for i := range configs {
+		dir := g.tenantDir(&configs[i])
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		v, err := g.pinnedTemplateVersion(&configs[i])
+		if err != nil {
+			return "", err
+		}
+		if version != "" && v != version {
+			return "", fmt.Errorf("replicas are on different template sets: %s on %s, %s on %s; upgrade them to one set first", pinnedBy, version, dir, v)
+		}
+		version, pinnedBy = v, dir
+	}
+	if version == "" {
+		return g.resolveTemplateVersion("")
+	}
+	return version, nil
+}
+
+// placementResult is the outcome on one cluster.
+type placementResult struct {
+	Region  string
+	Cluster string
+	Dir     string
+	Status  string
+	Err     error
+}
+
+// placeTenant generates config on every cluster of placement. All targets
+// are validated before any is written; after that a failing cluster does not
+// stop the others. It returns one result per cluster.
+func (g *Generator) placeTenant(config *Config, placement string) ([]placementResult, string, error) {
+	targets, err := g.resolvePlacement(config, placement)
+	if err != nil {
+		return nil, "", err
+	}
+	configs := placedConfigs(config, targets)
+
+	results := make([]placementResult, len(configs))
+	invalid := 0
+	for i := range configs {
+		results[i] = placementResult{Region: targets[i].Region, Cluster: targets[i].Cluster, Status: batchSkipped}
+		if err := g.checkConfig(&configs[i]); err != nil {
+			results[i].Status = batchFailed
+			results[i].Err = err
+			invalid++
+			continue
+		}
+		results[i].Dir = g.tenantDir(&configs[i])
+	}
+	if invalid > 0 {
+		return results, "", fmt.Errorf("%d of %d clusters rejected the tenant, nothing was generated", invalid, len(configs))
+	}
+
+	version, err := g.placementVersion(configs)
+	if err != nil {
+		return results, "", err
+	}
+	for i := range configs {
+		g.logger.Printf("Placing %s on %s/%s", tenantNamespace(&configs[i]), targets[i].Region, targets[i].Cluster)
+		if err := g.generateTenant(&configs[i], version); err != nil {
+			results[i].Status = batchFailed
+			results[i].Err = err
+			continue
+		}
+		results[i].Status = batchSucceeded
+	}
+	return results, version, nil
+}
+
+// printPlacementReport writes one line per cluster and a summary.
+func printPlacementReport(w io.Writer, namespace, version string, results []placementResult) {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		line := fmt.Sprintf("%-8s %s/%s", r.Status, r.Region, r.Cluster)
+		if r.Dir != "" {
+			line += "  " + r.Dir
+		}
+		if r.Err != nil {
+			line += "  " + r.Err.Error()
+		}
+		fmt.Fprintln(w, line)
+	}
+	set := ""
+	if version != "" {
+		set = " on template set " + version
+	}
+	fmt.Fprintf(w, "Placement summary for %s%s: %d clusters, %d succeeded, %d failed, %d skipped\n",
+		namespace, set, len(results), counts[batchSucceeded], counts[batchFailed], counts[batchSkipped])
+}
+
+// Place generates the tenant described by config on every cluster its
+// placement names, instead of the Region and ClusterName in config, and
+// reports the result per cluster to w.
+func (g *Generator) Place(w io.Writer, config *Config, placement string) error {
+	results, version, err := g.placeTenant(config, placement)
+	if results != nil {
+		printPlacementReport(w, tenantNamespace(config), version, results)
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Status != batchSucceeded {
+			return fmt.Errorf("placement %q did not complete on every cluster", placement)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListPlacement(t *testing.T) {
+	tests := []struct {
+		name    string
+		terms   []string
+		want    []placementTarget
+		wantErr bool
+	}{
+		{
+			name:  "one cluster",
+			terms: []string{"uksouth/aks1"},
+			want:  []placementTarget{{Region: "uksouth", Cluster: "aks1"}},
+		},
+		{
+			name:  "order is kept",
+			terms: []string{"ukwest/aks2", "uksouth/aks1"},
+			want:  []placementTarget{{Region: "ukwest", Cluster: "aks2"}, {Region: "uksouth", Cluster: "aks1"}},
+		},
+		{name: "no region", terms: []string{"aks1"}, wantErr: true},
+		{name: "empty cluster", terms: []string{"uksouth/"}, wantErr: true},
+		{name: "empty region", terms: []string{"/aks1"}, wantErr: true},
+		{name: "too many parts", terms: []string{"uksouth/aks1/extra"}, wantErr: true},
+		{name: "repeated", terms: []string{"uksouth/aks1", "uksouth/aks1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listPlacement(tt.terms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listPlacement(%q) error = %v, wantErr %v", tt.terms, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listPlacement(%q) = %v, want %v", tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePlacement(t *testing.T) {
+	root := t.TempDir()
+	catalog := `clusters:
+  - name: aks-uks-prod-01
+    region: uksouth
+    environments: [prod]
+    spot: false
+  - name: aks-ukw-prod-01
+    region: ukwest
+    environments: [prod]
+    spot: true
+  - name: aks-neu-prod-01
+    region: northeurope
+    environments: [prod]
+  - name: aks-uks-dev-01
+    region: uksouth
+    environments: [dev]
+regionPairs:
+  uk: [uksouth, ukwest]
+`
+	if err := os.WriteFile(filepath.Join(root, clusterCatalogFile), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noCatalog, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "prod", Swci: "ab12", Suffix: "web"}
+
+	tests := []struct {
+		name      string
+		g         *Generator
+		placement string
+		want      []placementTarget
+		wantErr   bool
+	}{
+		{
+			name:      "list is sorted",
+			g:         noCatalog,
+			placement: "ukwest/aks2, uksouth/aks1",
+			want:      []placementTarget{{Region: "uksouth", Cluster: "aks1"}, {Region: "ukwest", Cluster: "aks2"}},
+		},
+		{
+			name:      "region pair",
+			g:         g,
+			placement: "regionPair=uk",
+			want:      []placementTarget{{Region: "uksouth", Cluster: "aks-uks-prod-01"}, {Region: "ukwest", Cluster: "aks-ukw-prod-01"}},
+		},
+		{
+			name:      "every term must match",
+			g:         g,
+			placement: "regionPair=uk,spot=false",
+			want:      []placementTarget{{Region: "uksouth", Cluster: "aks-uks-prod-01"}},
+		},
+		{
+			name:      "only clusters hosting the environment",
+			g:         g,
+			placement: "region=uksouth",
+			want:      []placementTarget{{Region: "uksouth", Cluster: "aks-uks-prod-01"}},
+		},
+		{name: "empty", g: g, placement: " ", wantErr: true},
+		{name: "selector without a catalog", g: noCatalog, placement: "regionPair=uk", wantErr: true},
+		{name: "unknown key", g: g, placement: "zone=1", wantErr: true},
+		{name: "unknown region pair", g: g, placement: "regionPair=us", wantErr: true},
+		{name: "missing value", g: g, placement: "region=", wantErr: true},
+		{name: "no match", g: g, placement: "region=westeurope", wantErr: true},
+		{name: "bad list", g: g, placement: "uksouth", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.g.resolvePlacement(config, tt.placement)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePlacement(%q) error = %v, wantErr %v", tt.placement, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolvePlacement(%q) = %v, want %v", tt.placement, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchPlacesReplicasOnOneTemplateSet(t *testing.T) {
+	root := t.TempDir()
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	if err := g.generateTenant(existing, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := filepath.Join(t.TempDir(), "tenants.yaml")
+	data := `- opEnvironment: dev
+  swci: ab12
+  suffix: web
+  placement: uksouth/aks1,ukwest/aks2
+`
+	if err := os.WriteFile(manifest, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var report strings.Builder
+	if err := g.Batch(&report, manifest, BatchOptions{Parallelism: 2}); err != nil {
+		t.Fatalf("Batch: %v\n%s", err, report.String())
+	}
+
+	placed := &Config{OpEnvironment: "dev", Region: "ukwest", ClusterName: "aks2", Swci: "ab12", Suffix: "web"}
+	version, err := g.pinnedTemplateVersion(placed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1" {
+		t.Errorf("new replica is on template set %s, want v1 like the existing replica", version)
+	}
+}
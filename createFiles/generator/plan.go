@@ -0,0 +1,169 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Plan statuses for a single file in the tenant directory.
+const (
+	planAdded     = "added"
+	planChanged   = "changed"
+	planUnchanged = "unchanged"
+	planRemoved   = "removed"
+)
+
+// planFile is the planned outcome for one file in a tenant directory.
+type planFile struct {
+	Name   string
+	Status string
+	Diff   string
+}
+
+// tenantPlan compares what the templates would produce for a tenant with what
+// is currently in its directory.
+type tenantPlan struct {
+	Dir   string
+	Files []planFile
+}
+
+// count returns how many files have the given status.
+func (p *tenantPlan) count(status string) int {
+	n := 0
+	for _, f := range p.Files {
+		if f.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// hasChanges reports whether applying the plan would change anything.
+func (p *tenantPlan) hasChanges() bool {
+	return p.count(planUnchanged) != len(p.Files)
+}
+
+// planTenant renders a tenant from a template set in memory and diffs it
+// against its directory without writing anything.
+func (g *Generator) planTenant(config *Config, version string) (*tenantPlan, error) {
+	if err := g.checkConfig(config); err != nil {
+		return nil, err
+	}
+	dir := g.tenantDir(config)
+
+	rendered, err := g.renderTenant(config, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.checkRouting(config, rendered); err != nil {
+		return nil, err
+	}
+	if err := g.checkTenantClaims(config, rendered); err != nil {
+		return nil, err
+	}
+	current, err := readTenantFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply only removes managed orphans, and none at all in report mode
+	previous, err := readPreviousRecord(dir)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]bool, len(rendered))
+	for name := range rendered {
+		expected[name] = true
+	}
+	managed, err := g.managedFiles(previous)
+	if err != nil {
+		return nil, err
+	}
+	orphans, _ := findOrphans(current, expected, managed)
+	removed := make(map[string]bool, len(orphans))
+	if g.opts.OrphanMode == OrphansRemove {
+		for _, name := range orphans {
+			removed[name] = true
+		}
+	}
+	for name := range current {
+		if !expected[name] && !removed[name] {
+			delete(current, name)
+		}
+	}
+
+	return diffTenantFiles(dir, current, rendered), nil
+}
+
+// diffTenantFiles classifies every file in either set and attaches a unified
+// diff for anything that differs.
+func diffTenantFiles(dir string, current, rendered map[string][]byte) *tenantPlan {
+	names := make(map[string]bool)
+	for name := range current {
+		names[name] = true
+	}
+	for name := range rendered {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	plan := &tenantPlan{Dir: dir}
+	for _, name := range sorted {
+		oldData, inCurrent := current[name]
+		newData, inRendered := rendered[name]
+		path := filepath.Join(dir, name)
+
+		file := planFile{Name: name}
+		switch {
+		case !inCurrent:
+			file.Status = planAdded
+			file.Diff = unifiedDiff("/dev/null", path, nil, newData)
+		case !inRendered:
+			file.Status = planRemoved
+			file.Diff = unifiedDiff(path, "/dev/null", oldData, nil)
+		case string(oldData) == string(newData):
+			file.Status = planUnchanged
+		default:
+			file.Status = planChanged
+			file.Diff = unifiedDiff(path, path, oldData, newData)
+		}
+		plan.Files = append(plan.Files, file)
+	}
+	return plan
+}
+
+// printPlan writes the diffs followed by a one-line summary.
+func printPlan(w io.Writer, plan *tenantPlan) {
+	for _, file := range plan.Files {
+		if file.Diff != "" {
+			fmt.Fprint(w, file.Diff)
+		}
+	}
+	fmt.Fprintf(w, "Plan for %s: %d added, %d changed, %d unchanged, %d removed\n",
+		plan.Dir, plan.count(planAdded), plan.count(planChanged), plan.count(planUnchanged), plan.count(planRemoved))
+}
+
+// WritePlan is the dry-run counterpart of AddOrModify: it writes what would
+// change in the tenant directory to w and leaves the filesystem untouched.
+func (g *Generator) WritePlan(w io.Writer, config *Config) error {
+	g.logger.Printf("Planning tenant directory: %s", g.tenantDir(config))
+	if err := g.checkConfig(config); err != nil {
+		return err
+	}
+	version, err := g.pinnedTemplateVersion(config)
+	if err != nil {
+		return err
+	}
+	plan, err := g.planTenant(config, version)
+	if err != nil {
+		return err
+	}
+	printPlan(w, plan)
+	return nil
+}
@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tenantRecordFile is written into every generated tenant directory. It is
+// not referenced by the kustomization, so kustomize and Flux ignore it.
+const tenantRecordFile = ".tenant.yaml"
+
+// generatorVersion identifies the createFiles build that wrote a tenant.
+// Release builds set it with -ldflags
+// "-X github.com/davidmarkgardiner/scratchpad/createFiles/generator.generatorVersion=<version>".
+var generatorVersion = "dev"
+
+// tenantRecord holds everything needed to regenerate a tenant directory.
+type tenantRecord struct {
+	Inputs        map[string]string `yaml:"inputs"`
+	Variant       string            `yaml:"variant"`
+	VariantSource string            `yaml:"variantSource"`
+	// TemplateVersion pins the tenant to a template set. Only an upgrade
+	// moves it to another one.
+	TemplateVersion string `yaml:"templateVersion,omitempty"`
+	// TemplateSet fingerprints the contents of that set when generated.
+	TemplateSet      string    `yaml:"templateSet"`
+	GeneratedAt      time.Time `yaml:"generatedAt"`
+	GeneratorVersion string    `yaml:"generatorVersion"`
+	// Files maps each rendered file name to the sha256 of its contents, so
+	// later runs can tell hand edits from template changes.
+	Files map[string]string `yaml:"files,omitempty"`
+	// GitOps is the delivery tool the tenant's cluster used when generated.
+	GitOps string `yaml:"gitops,omitempty"`
+}
+
+// newTenantRecord captures the inputs and template selection for config.
+func (g *Generator) newTenantRecord(config *Config, version string, variant *variantRule) *tenantRecord {
+	inputs := make(map[string]string)
+	v := reflect.ValueOf(*config)
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.String {
+			inputs[t.Field(i).Name] = v.Field(i).String()
+		}
+	}
+
+	return &tenantRecord{
+		Inputs:           inputs,
+		Variant:          variant.Name,
+		VariantSource:    variant.Source,
+		TemplateVersion:  version,
+		TemplateSet:      g.templateSetVersion(version),
+		GeneratedAt:      time.Now().UTC(),
+		GeneratorVersion: generatorVersion,
+	}
+}
+
+// config rebuilds the Config the record was generated from.
+func (r *tenantRecord) config() (*Config, error) {
+	var config Config
+	for name, value := range r.Inputs {
+		if err := setConfigField(&config, name, value); err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
+
+// hashRenderedFiles records the checksum of every rendered file in dir.
+func (r *tenantRecord) hashRenderedFiles(dir string, files []templateFile) error {
+	r.Files = make(map[string]string, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Dest))
+		if err != nil {
+			return fmt.Errorf("failed to read rendered %s: %v", file.Dest, err)
+		}
+		r.Files[file.Dest] = contentHash(data)
+	}
+	return nil
+}
+
+// contentHash returns the checksum stored in tenant records.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// templateSetVersion fingerprints the effective contents of a template set so
+// a record shows which template contents produced it.
+func (g *Generator) templateSetVersion(version string) string {
+	sources, err := g.effectiveTemplates(version)
+	if err != nil {
+		return "unknown"
+	}
+	return fingerprintTemplates(sources)
+}
+
+// sameGeneration reports whether r and other record the same inputs,
+// template selection and file contents, differing at most in when and by
+// which build they were written.
+func (r *tenantRecord) sameGeneration(other *tenantRecord) bool {
+	if other == nil {
+		return false
+	}
+	a, b := *r, *other
+	a.GeneratedAt, b.GeneratedAt = time.Time{}, time.Time{}
+	a.GeneratorVersion, b.GeneratorVersion = "", ""
+	return reflect.DeepEqual(a, b)
+}
+
+// generatedFiles returns the checksums of the files r says were generated,
+// nil for a nil record.
+func (r *tenantRecord) generatedFiles() map[string]string {
+	if r == nil {
+		return nil
+	}
+	return r.Files
+}
+
+// templateVersion returns the template set the tenant is pinned to. Records
+// written before template sets were versioned are on legacyTemplateVersion.
+func (r *tenantRecord) templateVersion() string {
+	if r.TemplateVersion == "" {
+		return legacyTemplateVersion
+	}
+	return r.TemplateVersion
+}
+
+// writeTenantRecord stores record in dir.
+func writeTenantRecord(dir string, record *tenantRecord) error {
+	data, err := encodeYAML(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode tenant record: %v", err)
+	}
+	header := []byte("# Generated by createFiles. Used to regenerate this tenant, do not edit.\n")
+	path := filepath.Join(dir, tenantRecordFile)
+	if err := os.WriteFile(path, append(header, data...), 0644); err != nil {
+		return fmt.Errorf("failed to write tenant record %s: %v", path, err)
+	}
+	return nil
+}
+
+// readTenantRecord loads the record from a tenant directory.
+func readTenantRecord(dir string) (*tenantRecord, error) {
+	path := filepath.Join(dir, tenantRecordFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant record %s: %v", path, err)
+	}
+	var record tenantRecord
+	if err := yaml.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant record %s: %v", path, err)
+	}
+	return &record, nil
+}
+
+// Regenerate re-renders the tenant in dir purely from its record, using the
+// current templates.
+func (g *Generator) Regenerate(dir string) error {
+	record, err := readTenantRecord(dir)
+	if err != nil {
+		return err
+	}
+	config, err := record.config()
+	if err != nil {
+		return fmt.Errorf("tenant record in %s: %v", dir, err)
+	}
+
+	// The record must describe the directory it lives in
+	if filepath.Clean(g.tenantDir(config)) != filepath.Clean(dir) {
+		return fmt.Errorf("tenant record in %s describes %s", dir, g.tenantDir(config))
+	}
+
+	g.logger.Printf("Regenerating %s (generated %s by %s from template set %s %s, variant %s)",
+		dir, record.GeneratedAt.Format(time.RFC3339), record.GeneratorVersion, record.templateVersion(), record.TemplateSet, record.Variant)
+	return g.AddOrModify(config)
+}
@@ -0,0 +1,37 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnchangedTenantKeepsItsRecord(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web"}
+	path := filepath.Join(g.tenantDir(config), tenantRecordFile)
+	readRecord := func() []byte {
+		t.Helper()
+		if err := g.AddOrModify(config); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first := readRecord()
+	if again := readRecord(); !bytes.Equal(again, first) {
+		t.Errorf("re-running an unchanged tenant rewrote its record:\n%s\nwant\n%s", again, first)
+	}
+	config.FullDomainName = "web.apps.example.com"
+	if changed := readRecord(); bytes.Equal(changed, first) {
+		t.Error("changing an input kept the old record")
+	}
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// templateData is what overlay templates are executed against. Config is
+// embedded so the original {{.Swci}} style references keep working.
+type templateData struct {
+	*Config
+	// Namespace is the tenant namespace and directory name.
+	Namespace string
+	// EnvDir is the environment directory the tenant is stored under.
+	EnvDir string
+	// Repo is the parsed GitLabRepoURL, empty when there is none.
+	Repo *repoSource
+	// Route is how the tenant is exposed on its cluster.
+	Route *tenantRoute
+	// Cluster is the catalog entry of the tenant's cluster, empty when there
+	// is no catalog.
+	Cluster *catalogCluster
+}
+
+// newTemplateData builds the template data for config.
+func (g *Generator) newTemplateData(config *Config) (*templateData, error) {
+	route, err := g.routeFor(config)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := g.clusterFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &templateData{
+		Config:    config,
+		Namespace: tenantNamespace(config),
+		EnvDir:    g.envDirFor(config.OpEnvironment),
+		Repo:      repoSourceFor(config, g.opts.RepoHostKinds),
+		Route:     route,
+		Cluster:   cluster,
+	}, nil
+}
+
+// renderTemplateFile executes the template name of a template set for config.
+// Rendering is strict: a reference to an unknown field or map key fails
+// instead of producing "<no value>".
+func (g *Generator) renderTemplateFile(version, name string, config *Config) ([]byte, error) {
+	templates, err := g.templateFS(version)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").ParseFS(templates, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	data, err := g.newTemplateData(config)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// renderTenant renders every file a tenant would get from a template set into
+// memory, keyed by the file name inside the tenant directory.
+func (g *Generator) renderTenant(config *Config, version string) (map[string][]byte, error) {
+	_, files, err := g.selectTemplateFiles(config, version)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := g.renderTemplateFile(version, file.Source, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %v", file.Source, err)
+		}
+		rendered[file.Dest] = data
+	}
+	return rendered, nil
+}
+
+// readTenantFiles loads the regular files directly inside a tenant directory,
+// leaving out the tenant record. A missing directory yields an empty set.
+func readTenantFiles(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		// The tenant record carries a timestamp and is never rendered
+		if !entry.Type().IsRegular() || entry.Name() == tenantRecordFile {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", entry.Name(), err)
+		}
+		files[entry.Name()] = data
+	}
+	return files, nil
+}
@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Repository hosting kinds.
+const (
+	repoKindGitLab      = "gitlab"
+	repoKindGitHub      = "github"
+	repoKindAzureDevOps = "azuredevops"
+	repoKindGeneric     = "generic"
+)
+
+var (
+	scpLikeGitURL = regexp.MustCompile(`^([A-Za-z0-9._-]+)@([A-Za-z0-9.-]+):([^/].*)$`)
+	gitRefName    = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+)
+
+// repoSource is a tenant repository URL taken apart. Its string fields can be
+// used in variant and include conditions as Repo.<Field>, e.g.
+// "Repo.Kind equals gitlab".
+type repoSource struct {
+	// URL is the value as given.
+	URL string
+	// CloneURL is the URL Flux and Argo CD clone from: web paths, branch
+	// references and queries stripped, and user@host:path rewritten as
+	// ssh://user@host/path.
+	CloneURL string
+	Kind     string
+	// Scheme is https, http or ssh.
+	Scheme string
+	Host   string
+	// Group is everything between the host and the project: the GitLab group
+	// and subgroups, the GitHub owner or the Azure DevOps organisation/project.
+	Group   string
+	Project string
+	// Branch is taken from a "#branch" suffix, a ?ref= or ?version=GB query
+	// or a .../tree/<branch> web URL, and is empty when none is given.
+	Branch string
+}
+
+// parseRepoSource parses and classifies a Git repository URL. hostKinds
+// classifies hosts the name heuristics do not recognise.
+func parseRepoSource(raw string, hostKinds map[string]string) (*repoSource, error) {
+	src := &repoSource{URL: raw}
+	rest := raw
+	if i := strings.Index(rest, "#"); i >= 0 {
+		rest, src.Branch = rest[:i], rest[i+1:]
+	}
+
+	var path string
+	var u *url.URL
+	if m := scpLikeGitURL.FindStringSubmatch(rest); m != nil && !strings.Contains(rest, "://") {
+		src.Scheme = "ssh"
+		src.Host = strings.ToLower(m[2])
+		path = m[3]
+		u = &url.URL{Scheme: "ssh", User: url.User(m[1]), Host: m[2]}
+	} else {
+		var err error
+		u, err = url.Parse(rest)
+		if err != nil {
+			return nil, fmt.Errorf("not a URL: %v", err)
+		}
+		switch u.Scheme {
+		case "https", "http", "ssh":
+		default:
+			return nil, fmt.Errorf("must be an https, http or ssh URL or user@host:path")
+		}
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("has no host")
+		}
+		src.Scheme = u.Scheme
+		src.Host = strings.ToLower(u.Hostname())
+		path = u.Path
+
+		query := u.Query()
+		if ref := query.Get("ref"); ref != "" && src.Branch == "" {
+			src.Branch = ref
+		}
+		if version := query.Get("version"); strings.HasPrefix(version, "GB") && src.Branch == "" {
+			src.Branch = strings.TrimPrefix(version, "GB")
+		}
+		u.RawQuery = ""
+	}
+	u.Fragment = ""
+
+	src.Kind = classifyRepoHost(src.Host, hostKinds)
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	repoPath := segments
+
+	var err error
+	switch src.Kind {
+	case repoKindGitLab:
+		segments = src.trimWebPath(segments, "-", "tree")
+		repoPath = segments
+	case repoKindGitHub:
+		segments = src.trimWebPath(segments, "tree")
+		repoPath = segments
+		if len(segments) != 2 {
+			return nil, fmt.Errorf("GitHub repository must be <owner>/<repo>")
+		}
+	case repoKindAzureDevOps:
+		segments, err = azureDevOpsSegments(src.Host, segments)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(segments) < 2 {
+		return nil, fmt.Errorf("must name a group and a project")
+	}
+
+	src.Project = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	src.Group = strings.Join(segments[:len(segments)-1], "/")
+	if src.Project == "" {
+		return nil, fmt.Errorf("has an empty project name")
+	}
+	if src.Branch != "" && !isGitRefName(src.Branch) {
+		return nil, fmt.Errorf("branch %q is not a valid Git branch name", src.Branch)
+	}
+
+	u.Path = "/" + strings.Join(repoPath, "/")
+	src.CloneURL = u.String()
+	return src, nil
+}
+
+// classifyRepoHost works out the hosting kind from a host name, trying
+// hostKinds first.
+func classifyRepoHost(host string, hostKinds map[string]string) string {
+	if kind, ok := hostKinds[host]; ok {
+		return kind
+	}
+	switch {
+	case host == "github.com" || strings.HasSuffix(host, ".github.com"):
+		return repoKindGitHub
+	case host == "dev.azure.com" || host == "ssh.dev.azure.com" || strings.HasSuffix(host, ".visualstudio.com"):
+		return repoKindAzureDevOps
+	case strings.Contains(host, "gitlab"):
+		return repoKindGitLab
+	}
+	return repoKindGeneric
+}
+
+// trimWebPath cuts a browser URL such as group/project/-/tree/<branch> back
+// to the repository path, taking the branch from it when none was given.
+func (s *repoSource) trimWebPath(segments []string, marker ...string) []string {
+	for i := 0; i+len(marker) <= len(segments); i++ {
+		if strings.Join(segments[i:i+len(marker)], "/") != strings.Join(marker, "/") {
+			continue
+		}
+		if s.Branch == "" {
+			s.Branch = strings.Join(segments[i+len(marker):], "/")
+		}
+		return segments[:i]
+	}
+	return segments
+}
+
+// azureDevOpsSegments reduces the Azure DevOps URL layouts to
+// organisation/project/repository:
+//
+//	https://dev.azure.com/<org>/<project>/_git/<repo>
+//	https://<org>.visualstudio.com/<project>/_git/<repo>
+//	git@ssh.dev.azure.com:v3/<org>/<project>/<repo>
+func azureDevOpsSegments(host string, segments []string) ([]string, error) {
+	if host == "ssh.dev.azure.com" {
+		if len(segments) != 4 || segments[0] != "v3" {
+			return nil, fmt.Errorf("Azure DevOps SSH URL must be v3/<organisation>/<project>/<repo>")
+		}
+		return segments[1:], nil
+	}
+	if strings.HasSuffix(host, ".visualstudio.com") {
+		org := strings.TrimSuffix(host, ".visualstudio.com")
+		if len(segments) > 0 && segments[0] == "DefaultCollection" {
+			segments = segments[1:]
+		}
+		segments = append([]string{org}, segments...)
+	}
+	if len(segments) != 4 || segments[2] != "_git" {
+		return nil, fmt.Errorf("Azure DevOps URL must be <organisation>/<project>/_git/<repo>")
+	}
+	return []string{segments[0], segments[1], segments[3]}, nil
+}
+
+// isGitRefName applies the subset of git check-ref-format rules that matter
+// for branch names typed into a form.
+func isGitRefName(name string) bool {
+	return gitRefName.MatchString(name) &&
+		!strings.Contains(name, "..") && !strings.Contains(name, "//") &&
+		!strings.HasPrefix(name, "-") && !strings.HasPrefix(name, "/") &&
+		!strings.HasSuffix(name, "/") && !strings.HasSuffix(name, ".lock")
+}
+
+// repoSourceFor returns the parsed tenant repository, or an empty repoSource
+// when the tenant has none. Config is validated before this is used, so a
+// parse error only leaves the fields empty.
+func repoSourceFor(config *Config, hostKinds map[string]string) *repoSource {
+	if config.GitLabRepoURL == "" {
+		return &repoSource{}
+	}
+	src, err := parseRepoSource(config.GitLabRepoURL, hostKinds)
+	if err != nil {
+		return &repoSource{URL: config.GitLabRepoURL}
+	}
+	return src
+}
@@ -0,0 +1,78 @@
+package generator
+
+import "testing"
+
+func TestParseRepoSource(t *testing.T) {
+	tests := []struct {
+		raw       string
+		hostKinds map[string]string
+		want      repoSource
+		wantErr   bool
+	}{
+		{
+			raw:  "https://gitlab.example.com/grp/sub/proj.git",
+			want: repoSource{CloneURL: "https://gitlab.example.com/grp/sub/proj.git", Kind: repoKindGitLab, Scheme: "https", Host: "gitlab.example.com", Group: "grp/sub", Project: "proj"},
+		},
+		{
+			raw:  "git@gitlab.example.com:grp/proj.git#main",
+			want: repoSource{CloneURL: "ssh://git@gitlab.example.com/grp/proj.git", Kind: repoKindGitLab, Scheme: "ssh", Host: "gitlab.example.com", Group: "grp", Project: "proj", Branch: "main"},
+		},
+		{
+			raw:  "https://gitlab.example.com/grp/proj/-/tree/release/1.0",
+			want: repoSource{CloneURL: "https://gitlab.example.com/grp/proj", Kind: repoKindGitLab, Scheme: "https", Host: "gitlab.example.com", Group: "grp", Project: "proj", Branch: "release/1.0"},
+		},
+		{
+			raw:  "https://github.com/org/repo/tree/dev",
+			want: repoSource{CloneURL: "https://github.com/org/repo", Kind: repoKindGitHub, Scheme: "https", Host: "github.com", Group: "org", Project: "repo", Branch: "dev"},
+		},
+		{
+			raw:  "https://dev.azure.com/org/proj/_git/repo?version=GBmain",
+			want: repoSource{CloneURL: "https://dev.azure.com/org/proj/_git/repo", Kind: repoKindAzureDevOps, Scheme: "https", Host: "dev.azure.com", Group: "org/proj", Project: "repo", Branch: "main"},
+		},
+		{
+			raw:  "https://myorg.visualstudio.com/DefaultCollection/proj/_git/repo",
+			want: repoSource{CloneURL: "https://myorg.visualstudio.com/DefaultCollection/proj/_git/repo", Kind: repoKindAzureDevOps, Scheme: "https", Host: "myorg.visualstudio.com", Group: "myorg/proj", Project: "repo"},
+		},
+		{
+			raw:  "git@ssh.dev.azure.com:v3/org/proj/repo",
+			want: repoSource{CloneURL: "ssh://git@ssh.dev.azure.com/v3/org/proj/repo", Kind: repoKindAzureDevOps, Scheme: "ssh", Host: "ssh.dev.azure.com", Group: "org/proj", Project: "repo"},
+		},
+		{
+			raw:  "https://git.example.com/team/app?ref=feature-x",
+			want: repoSource{CloneURL: "https://git.example.com/team/app", Kind: repoKindGeneric, Scheme: "https", Host: "git.example.com", Group: "team", Project: "app", Branch: "feature-x"},
+		},
+		{
+			raw:       "https://git.internal.example.com/grp/proj/-/tree/main",
+			hostKinds: map[string]string{"git.internal.example.com": repoKindGitLab},
+			want:      repoSource{CloneURL: "https://git.internal.example.com/grp/proj", Kind: repoKindGitLab, Scheme: "https", Host: "git.internal.example.com", Group: "grp", Project: "proj", Branch: "main"},
+		},
+		{
+			raw:  "https://git.internal.example.com/grp/proj",
+			want: repoSource{CloneURL: "https://git.internal.example.com/grp/proj", Kind: repoKindGeneric, Scheme: "https", Host: "git.internal.example.com", Group: "grp", Project: "proj"},
+		},
+		{raw: "not a url", wantErr: true},
+		{raw: "ftp://gitlab.example.com/grp/proj", wantErr: true},
+		{raw: "https:///grp/proj", wantErr: true},
+		{raw: "https://gitlab.example.com/proj", wantErr: true},
+		{raw: "https://gitlab.example.com/grp/.git", wantErr: true},
+		{raw: "https://github.com/org/repo/extra", wantErr: true},
+		{raw: "https://dev.azure.com/org/proj/repo", wantErr: true},
+		{raw: "git@ssh.dev.azure.com:org/proj/repo", wantErr: true},
+		{raw: "https://gitlab.example.com/grp/proj#bad..branch", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseRepoSource(tt.raw, tt.hostKinds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRepoSource(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tt.want.URL = tt.raw
+			if *got != tt.want {
+				t.Errorf("parseRepoSource(%q) = %+v, want %+v", tt.raw, *got, tt.want)
+			}
+		})
+	}
+}
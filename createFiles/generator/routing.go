@@ -0,0 +1,320 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// routingSettingsFile, looked up in OutputRoot, describes how tenants are
+// exposed on each cluster and which domains they may use. Clusters in the
+// cluster catalog take their routing from there. Without it tenants
+// get an Istio VirtualService on the default gateway and any domain.
+const routingSettingsFile = "routing.yaml"
+
+// Cluster types, deciding which routing object a tenant gets.
+const (
+	routingIstio      = "istio"
+	routingGatewayAPI = "gatewayapi"
+)
+
+// gatewayRef names the shared gateway tenant routes attach to.
+type gatewayRef struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+	// SectionName is the Gateway API listener routes attach to. Empty
+	// attaches to every listener.
+	SectionName string `yaml:"sectionName,omitempty"`
+}
+
+// String returns the "namespace/name" form Istio uses.
+func (g gatewayRef) String() string {
+	return g.Namespace + "/" + g.Name
+}
+
+// clusterRouting is the routing setup of one cluster.
+type clusterRouting struct {
+	// Type is "istio" or "gatewayapi".
+	Type    string     `yaml:"type"`
+	Gateway gatewayRef `yaml:"gateway"`
+}
+
+// routingSettings is the parsed routingSettingsFile.
+type routingSettings struct {
+	Default clusterRouting `yaml:"default"`
+	// Clusters overrides the default per cluster name.
+	Clusters map[string]clusterRouting `yaml:"clusters,omitempty"`
+	// DomainSuffixes are the domains every tenant's hostnames must be below.
+	// Empty allows any domain.
+	DomainSuffixes []string `yaml:"domainSuffixes,omitempty"`
+	// TenantDomainSuffixes replaces DomainSuffixes per Swci.
+	TenantDomainSuffixes map[string][]string `yaml:"tenantDomainSuffixes,omitempty"`
+	// ServicePort is the port of the tenant service routes send traffic to.
+	ServicePort int `yaml:"servicePort,omitempty"`
+}
+
+// defaultClusterRouting is used when there is no routingSettingsFile or it
+// leaves fields out.
+var defaultClusterRouting = clusterRouting{
+	Type:    routingIstio,
+	Gateway: gatewayRef{Name: "gateway", Namespace: "istio-system"},
+}
+
+// loadRoutingSettings reads and validates the settings file in dir. A missing
+// file yields the defaults.
+func (g *Generator) loadRoutingSettings(dir string) (*routingSettings, error) {
+	path := filepath.Join(dir, routingSettingsFile)
+	settings := &routingSettings{Default: defaultClusterRouting}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		g.logger.Printf("No %s found, routing tenants through Istio gateway %s", path, defaultClusterRouting.Gateway)
+		settings.ServicePort = 80
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if settings.ServicePort == 0 {
+		settings.ServicePort = 80
+	}
+
+	settings.Default = settings.Default.withDefaults(defaultClusterRouting)
+	if err := settings.Default.check(); err != nil {
+		return nil, fmt.Errorf("%s: default: %v", path, err)
+	}
+	for cluster, routing := range settings.Clusters {
+		routing = routing.withDefaults(settings.Default)
+		if err := routing.check(); err != nil {
+			return nil, fmt.Errorf("%s: cluster %s: %v", path, cluster, err)
+		}
+		settings.Clusters[cluster] = routing
+	}
+
+	suffixes := append([]string{}, settings.DomainSuffixes...)
+	for _, list := range settings.TenantDomainSuffixes {
+		suffixes = append(suffixes, list...)
+	}
+	for _, suffix := range suffixes {
+		if msg := hostnameProblem(suffix); msg != "" {
+			return nil, fmt.Errorf("%s: domain suffix %q %s", path, suffix, msg)
+		}
+	}
+	if settings.ServicePort < 1 || settings.ServicePort > 65535 {
+		return nil, fmt.Errorf("%s: invalid servicePort %d", path, settings.ServicePort)
+	}
+	return settings, nil
+}
+
+// withDefaults fills the fields r leaves empty from def.
+func (r clusterRouting) withDefaults(def clusterRouting) clusterRouting {
+	if r.Type == "" {
+		r.Type = def.Type
+	}
+	if r.Gateway.Name == "" {
+		r.Gateway = def.Gateway
+	}
+	return r
+}
+
+// check validates a cluster's routing setup.
+func (r clusterRouting) check() error {
+	if r.Type != routingIstio && r.Type != routingGatewayAPI {
+		return fmt.Errorf("unknown type %q, use %s or %s", r.Type, routingIstio, routingGatewayAPI)
+	}
+	if r.Gateway.Name == "" || r.Gateway.Namespace == "" {
+		return fmt.Errorf("gateway name and namespace are required")
+	}
+	if r.Gateway.SectionName != "" && r.Type != routingGatewayAPI {
+		return fmt.Errorf("gateway sectionName only applies to %s", routingGatewayAPI)
+	}
+	return nil
+}
+
+// cachedRoutingSettings loads the settings from OutputRoot once.
+func (g *Generator) cachedRoutingSettings() (*routingSettings, error) {
+	g.routingSettingsOnce.Do(func() {
+		g.routingLoaded, g.routingLoadErr = g.loadRoutingSettings(g.opts.OutputRoot)
+	})
+	return g.routingLoaded, g.routingLoadErr
+}
+
+// tenantRoute is how a tenant is exposed, available to templates as .Route.
+type tenantRoute struct {
+	// Type is "istio" or "gatewayapi"; templates render a VirtualService or
+	// an HTTPRoute accordingly.
+	Type    string
+	Gateway gatewayRef
+	// DomainSuffixes are the domains the tenant's hostnames must be below,
+	// empty when any domain is allowed.
+	DomainSuffixes []string
+	ServicePort    int
+}
+
+// routeFor resolves the routing of config's cluster and its allowed domains.
+// Routing and gateway set in the cluster catalog win over the settings.
+func (g *Generator) routeFor(config *Config) (*tenantRoute, error) {
+	settings, err := g.cachedRoutingSettings()
+	if err != nil {
+		return nil, err
+	}
+	routing, ok := settings.Clusters[config.ClusterName]
+	if !ok {
+		routing = settings.Default
+	}
+	// The cluster catalog has the final say over the cluster's routing
+	cluster, err := g.clusterFor(config)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Routing != "" {
+		routing.Type = cluster.Routing
+	}
+	if cluster.Gateway.Name != "" {
+		routing.Gateway = cluster.Gateway
+	}
+	suffixes, ok := settings.TenantDomainSuffixes[config.Swci]
+	if !ok {
+		suffixes = settings.DomainSuffixes
+	}
+	return &tenantRoute{
+		Type:           routing.Type,
+		Gateway:        routing.Gateway,
+		DomainSuffixes: suffixes,
+		ServicePort:    settings.ServicePort,
+	}, nil
+}
+
+// allowsHostname reports whether host, or the domain of a wildcard host, is
+// strictly below one of the allowed domain suffixes.
+func (r *tenantRoute) allowsHostname(host string) bool {
+	if len(r.DomainSuffixes) == 0 {
+		return true
+	}
+	name := strings.TrimPrefix(strings.ToLower(host), "*.")
+	for _, suffix := range r.DomainSuffixes {
+		if name != strings.ToLower(suffix) && hostUnder(suffix, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRouting verifies a tenant's rendered files against its cluster's
+// routing: every hostname, including FullDomainName, must be below an allowed
+// domain suffix, and HTTPRoutes and VirtualServices may only attach to the
+// gateway assigned to the cluster.
+func (g *Generator) checkRouting(config *Config, files map[string][]byte) error {
+	route, err := g.routeFor(config)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := hostnamesInFiles(files)
+	if err != nil {
+		return err
+	}
+	hosts = addHostname(hosts, config.FullDomainName)
+	var problems []string
+	for _, host := range hosts {
+		if !route.allowsHostname(host) {
+			problems = append(problems, fmt.Sprintf("hostname %s is not below %s", host, strings.Join(route.DomainSuffixes, ", ")))
+		}
+	}
+
+	namespace := tenantNamespace(config)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if filepath.Ext(name) != ".yaml" || strings.HasPrefix(name, "kustomization") {
+			continue
+		}
+		refs, err := gatewayRefsInYAML(files[name], namespace)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		for _, ref := range refs {
+			if ref != route.Gateway.String() {
+				problems = append(problems, fmt.Sprintf("%s attaches to gateway %s, cluster %s only allows %s", name, ref, config.ClusterName, route.Gateway))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("routing not allowed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// gatewayRefsInYAML returns the gateways the HTTPRoutes and VirtualServices
+// in a YAML stream attach to, as "namespace/name". References without a
+// namespace resolve to namespace; the Istio "mesh" gateway and HTTPRoute
+// parents that are not Gateways are left out.
+func gatewayRefsInYAML(data []byte, namespace string) ([]string, error) {
+	var refs []string
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+			Spec struct {
+				ParentRefs []struct {
+					Kind      string `yaml:"kind"`
+					Name      string `yaml:"name"`
+					Namespace string `yaml:"namespace"`
+				} `yaml:"parentRefs"`
+				Gateways []string `yaml:"gateways"`
+			} `yaml:"spec"`
+		}
+		err := decoder.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		local := namespace
+		if obj.Metadata.Namespace != "" {
+			local = obj.Metadata.Namespace
+		}
+		switch obj.Kind {
+		case "HTTPRoute":
+			for _, parent := range obj.Spec.ParentRefs {
+				if parent.Kind != "" && parent.Kind != "Gateway" {
+					continue
+				}
+				ns := parent.Namespace
+				if ns == "" {
+					ns = local
+				}
+				refs = append(refs, ns+"/"+parent.Name)
+			}
+		case "VirtualService":
+			for _, gateway := range obj.Spec.Gateways {
+				if gateway == "mesh" {
+					continue
+				}
+				if !strings.Contains(gateway, "/") {
+					gateway = local + "/" + gateway
+				}
+				refs = append(refs, gateway)
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAllowsHostname(t *testing.T) {
+	route := &tenantRoute{DomainSuffixes: []string{"apps.example.com", "Payments.Example.com"}}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "web.apps.example.com", want: true},
+		{host: "a.b.apps.example.com", want: true},
+		{host: "WEB.Apps.Example.com", want: true},
+		{host: "web.payments.example.com", want: true},
+		{host: "*.team.apps.example.com", want: true},
+		{host: "*.apps.example.com", want: false},
+		{host: "apps.example.com", want: false},
+		{host: "webapps.example.com", want: false},
+		{host: "web.example.com", want: false},
+		{host: "web.apps.example.com.evil.io", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := route.allowsHostname(tt.host); got != tt.want {
+				t.Errorf("allowsHostname(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+
+	if open := (&tenantRoute{}); !open.allowsHostname("anything.example.org") {
+		t.Error("a route without domain suffixes rejected a hostname")
+	}
+}
+
+func TestGatewayRefsInYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "virtual service",
+			yaml: "kind: VirtualService\nspec:\n  gateways: [istio-system/gateway, local-gw, mesh]\n",
+			want: []string{"istio-system/gateway", "ab12-dev-web/local-gw"},
+		},
+		{
+			name: "metadata namespace resolves bare names",
+			yaml: "kind: VirtualService\nmetadata:\n  namespace: other\nspec:\n  gateways: [gw]\n",
+			want: []string{"other/gw"},
+		},
+		{
+			name: "http route",
+			yaml: "kind: HTTPRoute\nspec:\n  parentRefs:\n    - name: shared\n      namespace: gateway-system\n    - name: local\n    - name: svc\n      kind: Service\n",
+			want: []string{"gateway-system/shared", "ab12-dev-web/local"},
+		},
+		{
+			name: "every document is read",
+			yaml: "kind: VirtualService\nspec:\n  gateways: [a/one]\n---\nkind: HTTPRoute\nspec:\n  parentRefs:\n    - name: two\n      kind: Gateway\n      namespace: b\n",
+			want: []string{"a/one", "b/two"},
+		},
+		{name: "other kinds are ignored", yaml: "kind: Service\nspec:\n  gateways: [a/one]\n"},
+		{name: "invalid yaml", yaml: "kind: [", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gatewayRefsInYAML([]byte(tt.yaml), "ab12-dev-web")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("gatewayRefsInYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gatewayRefsInYAML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRouting(t *testing.T) {
+	root := t.TempDir()
+	settings := "default:\n  type: istio\n  gateway:\n    name: gateway\n    namespace: istio-system\ndomainSuffixes: [apps.example.com]\n"
+	if err := os.WriteFile(filepath.Join(root, routingSettingsFile), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(Options{OutputRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	virtualService := func(gateway, host string) map[string][]byte {
+		return map[string][]byte{
+			"route.yaml": []byte("kind: VirtualService\nspec:\n  gateways: [" + gateway + "]\n  hosts: [\"" + host + "\"]\n"),
+			// Kustomizations are not routing objects
+			"kustomization.yaml": []byte("kind: VirtualService\nspec:\n  gateways: [rogue/gateway]\n"),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		domain  string
+		files   map[string][]byte
+		wantErr string
+	}{
+		{name: "allowed host and gateway", domain: "web.apps.example.com", files: virtualService("istio-system/gateway", "web.apps.example.com")},
+		{name: "wildcard below a suffix", files: virtualService("istio-system/gateway", "*.web.apps.example.com")},
+		{name: "unknown gateway", files: virtualService("other/gateway", "web.apps.example.com"), wantErr: "route.yaml attaches to gateway other/gateway"},
+		{name: "host outside the suffixes", files: virtualService("istio-system/gateway", "web.example.com"), wantErr: "hostname web.example.com is not below apps.example.com"},
+		{name: "domain outside the suffixes", domain: "web.example.org", wantErr: "hostname web.example.org is not below"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{OpEnvironment: "dev", Region: "uksouth", ClusterName: "aks1", Swci: "ab12", Suffix: "web", FullDomainName: tt.domain}
+			err := g.checkRouting(config, tt.files)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("checkRouting() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("checkRouting() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
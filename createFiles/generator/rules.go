@@ -0,0 +1,299 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variantRulesFile is the rules file looked up in each template set, so it can
+// be supplied in TemplateDir. When it is absent the built-in
+// defaultVariantRules apply.
+const variantRulesFile = "variants.yaml"
+
+// defaultVariantRules are the built-in rules of every template set after the
+// legacy one: the original hardcoded selection order, with the GitLab URL
+// prefix checks replaced by the parsed repository kind.
+var defaultVariantRules = []variantRule{
+	{Name: "git-gate", Source: "kustomization-git-gate.yaml", When: []string{"FullDomainName set", "Repo.Kind equals gitlab"}},
+	{Name: "gateway", Source: "kustomization-gateway.yaml", When: []string{"FullDomainName set"}},
+	{Name: "gitrepo", Source: "kustomization-gitrepo.yaml", When: []string{"Repo.Kind equals gitlab"}},
+	{Name: "apptest", Source: "kustomization-apptest.yaml", When: []string{"Suffix contains ob-test"}},
+	{Name: "default", Source: "kustomization.yaml"},
+}
+
+// legacyVariantRules are the built-in rules of legacyTemplateVersion. They
+// keep the original URL prefix checks, so tenants pinned to the legacy set
+// keep the variant they were generated with.
+var legacyVariantRules = []variantRule{
+	{Name: "git-gate", Source: "kustomization-git-gate.yaml", When: []string{"FullDomainName set", "GitLabRepoURL prefix sdgois`hbff"}},
+	{Name: "gateway", Source: "kustomization-gateway.yaml", When: []string{"FullDomainName set"}},
+	{Name: "gitrepo", Source: "kustomization-gitrepo.yaml", When: []string{"GitLabRepoURL prefix sfs`dfdf"}},
+	{Name: "apptest", Source: "kustomization-apptest.yaml", When: []string{"Suffix contains ob-test"}},
+	{Name: "default", Source: "kustomization.yaml"},
+}
+
+// variantRule maps a set of conditions on Config to the kustomization template
+// a tenant gets. Rules are evaluated in order and the first match wins.
+type variantRule struct {
+	Name   string   `yaml:"name"`
+	Source string   `yaml:"source"`
+	When   []string `yaml:"when"`
+
+	conditions []condition
+}
+
+type variantRuleFile struct {
+	Rules []variantRule `yaml:"rules"`
+}
+
+// condition is a single "<Field> <op> [value]" test against a Config field.
+type condition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// conditionOps lists the supported operators and whether they take a value.
+var conditionOps = map[string]bool{
+	"set":       false,
+	"unset":     false,
+	"equals":    true,
+	"notequals": true,
+	"prefix":    true,
+	"contains":  true,
+}
+
+// parseCondition parses "<Field> <op> [value]". The value is the rest of the
+// line and may be double-quoted to keep surrounding spaces.
+func parseCondition(expr string) (condition, error) {
+	parts := strings.SplitN(strings.TrimSpace(expr), " ", 3)
+	if len(parts) < 2 {
+		return condition{}, fmt.Errorf("condition %q: expected \"<Field> <op> [value]\"", expr)
+	}
+	cond := condition{Field: parts[0], Op: strings.ToLower(parts[1])}
+
+	needsValue, ok := conditionOps[cond.Op]
+	if !ok {
+		return condition{}, fmt.Errorf("condition %q: unknown operator %q", expr, parts[1])
+	}
+	if needsValue {
+		if len(parts) < 3 {
+			return condition{}, fmt.Errorf("condition %q: operator %s needs a value", expr, cond.Op)
+		}
+		cond.Value = strings.TrimSpace(parts[2])
+		if strings.HasPrefix(cond.Value, `"`) {
+			unquoted, err := strconv.Unquote(cond.Value)
+			if err != nil {
+				return condition{}, fmt.Errorf("condition %q: bad quoted value: %v", expr, err)
+			}
+			cond.Value = unquoted
+		}
+	} else if len(parts) > 2 {
+		return condition{}, fmt.Errorf("condition %q: operator %s takes no value", expr, cond.Op)
+	}
+
+	if !isConditionField(cond.Field) {
+		return condition{}, fmt.Errorf("condition %q: unknown Config field %q", expr, cond.Field)
+	}
+	return cond, nil
+}
+
+// repoFieldPrefix selects a field of the parsed tenant repository instead of
+// a Config field, e.g. "Repo.Kind".
+const repoFieldPrefix = "Repo."
+
+// isConditionField reports whether field names a string field of Config or,
+// with repoFieldPrefix, of repoSource.
+func isConditionField(field string) bool {
+	t, name := reflect.TypeOf(Config{}), field
+	if strings.HasPrefix(field, repoFieldPrefix) {
+		t, name = reflect.TypeOf(repoSource{}), strings.TrimPrefix(field, repoFieldPrefix)
+	}
+	f, ok := t.FieldByName(name)
+	return ok && f.Type.Kind() == reflect.String
+}
+
+// fieldValue returns the named string field of config or its repository.
+func (c condition) fieldValue(config *Config, repo *repoSource) string {
+	if strings.HasPrefix(c.Field, repoFieldPrefix) {
+		return reflect.ValueOf(repo).Elem().FieldByName(strings.TrimPrefix(c.Field, repoFieldPrefix)).String()
+	}
+	return reflect.ValueOf(config).Elem().FieldByName(c.Field).String()
+}
+
+// matches evaluates the condition against config and its parsed repository.
+func (c condition) matches(config *Config, repo *repoSource) bool {
+	value := c.fieldValue(config, repo)
+	switch c.Op {
+	case "set":
+		return value != ""
+	case "unset":
+		return value == ""
+	case "equals":
+		return value == c.Value
+	case "notequals":
+		return value != c.Value
+	case "prefix":
+		return strings.HasPrefix(value, c.Value)
+	case "contains":
+		return strings.Contains(value, c.Value)
+	}
+	return false
+}
+
+// explain describes the condition together with the value it was tested on.
+func (c condition) explain(config *Config, repo *repoSource) string {
+	expr := c.Field + " " + c.Op
+	if conditionOps[c.Op] {
+		expr += " " + strconv.Quote(c.Value)
+	}
+	return fmt.Sprintf("%s (value %q)", expr, c.fieldValue(config, repo))
+}
+
+// validateVariantRules parses every condition and checks the rule set is
+// usable: unique names, sources that exist in templates and a final
+// catch-all rule.
+func validateVariantRules(rules []variantRule, templates fs.FS) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("no variant rules defined")
+	}
+	seen := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if rule.Source == "" || path.Base(rule.Source) != rule.Source {
+			return fmt.Errorf("rule %s: source must be a file name in the template set", rule.Name)
+		}
+		if _, err := fs.Stat(templates, rule.Source); err != nil {
+			return fmt.Errorf("rule %s: source %s: %v", rule.Name, rule.Source, err)
+		}
+
+		rule.conditions = nil
+		for _, expr := range rule.When {
+			cond, err := parseCondition(expr)
+			if err != nil {
+				return fmt.Errorf("rule %s: %v", rule.Name, err)
+			}
+			rule.conditions = append(rule.conditions, cond)
+		}
+	}
+	if last := rules[len(rules)-1]; len(last.conditions) != 0 {
+		return fmt.Errorf("rule %s: the last rule must have no conditions so every tenant matches", last.Name)
+	}
+	return nil
+}
+
+// loadVariantRules reads the rules file of a template set, falling back to
+// the built-in rules of the set when there is none, and validates the result.
+func (g *Generator) loadVariantRules(templates fs.FS, version string) ([]variantRule, error) {
+	rules := append([]variantRule(nil), defaultVariantRules...)
+	if version == legacyTemplateVersion {
+		rules = append([]variantRule(nil), legacyVariantRules...)
+	}
+
+	data, err := fs.ReadFile(templates, variantRulesFile)
+	if err == nil {
+		var file variantRuleFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", variantRulesFile, err)
+		}
+		rules = file.Rules
+		g.logger.Printf("Loaded %d kustomization variant rules from %s of template set %s", len(rules), variantRulesFile, version)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		g.logger.Printf("No %s in template set %s, using built-in kustomization variant rules", variantRulesFile, version)
+	} else {
+		return nil, fmt.Errorf("failed to read %s: %v", variantRulesFile, err)
+	}
+
+	if err := validateVariantRules(rules, templates); err != nil {
+		return nil, fmt.Errorf("invalid variant rules: %v", err)
+	}
+	return rules, nil
+}
+
+// variantRulesFor loads and validates the rules of a template set once per
+// Generator.
+func (g *Generator) variantRulesFor(version string) ([]variantRule, error) {
+	g.variantRulesMu.Lock()
+	defer g.variantRulesMu.Unlock()
+	if rules, ok := g.variantRulesByVersion[version]; ok {
+		return rules, nil
+	}
+
+	templates, err := g.templateFS(version)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := g.loadVariantRules(templates, version)
+	if err != nil {
+		return nil, fmt.Errorf("template set %s: %v", version, err)
+	}
+	if sources, err := g.effectiveTemplates(version); err == nil {
+		g.logger.Printf("Using template set %s %s (%d templates)", version, fingerprintTemplates(sources), len(sources))
+	}
+	g.variantRulesByVersion[version] = rules
+	return rules, nil
+}
+
+// matchVariantRule returns the first rule of a template set whose conditions
+// all hold for config, logging why it matched and why earlier rules did not.
+func (g *Generator) matchVariantRule(config *Config, version string) (*variantRule, error) {
+	rules, err := g.variantRulesFor(version)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := repoSourceFor(config, g.opts.RepoHostKinds)
+	for i := range rules {
+		rule := &rules[i]
+		var reasons []string
+		matched := true
+		for _, cond := range rule.conditions {
+			if !cond.matches(config, repo) {
+				g.logger.Printf("Variant rule %s skipped: %s is false", rule.Name, cond.explain(config, repo))
+				matched = false
+				break
+			}
+			reasons = append(reasons, cond.explain(config, repo))
+		}
+		if !matched {
+			continue
+		}
+
+		if len(reasons) == 0 {
+			reasons = append(reasons, "no conditions")
+		}
+		g.logger.Printf("Variant rule %s matched (%s), using %s", rule.Name, strings.Join(reasons, ", "), rule.Source)
+		return rule, nil
+	}
+	return nil, fmt.Errorf("no kustomization variant rule matched")
+}
+
+// isVariantSource reports whether name is the source of any variant rule, so
+// the overlay loop does not render it a second time.
+func (g *Generator) isVariantSource(version, name string) (bool, error) {
+	rules, err := g.variantRulesFor(version)
+	if err != nil {
+		return false, err
+	}
+	for _, rule := range rules {
+		if rule.Source == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,122 @@
+package generator
+
+import "testing"
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    condition
+		wantErr bool
+	}{
+		{expr: "FullDomainName set", want: condition{Field: "FullDomainName", Op: "set"}},
+		{expr: "  Suffix UNSET ", want: condition{Field: "Suffix", Op: "unset"}},
+		{expr: "Suffix contains ob-test", want: condition{Field: "Suffix", Op: "contains", Value: "ob-test"}},
+		{expr: "Swci equals a b c", want: condition{Field: "Swci", Op: "equals", Value: "a b c"}},
+		{expr: `Suffix prefix " padded "`, want: condition{Field: "Suffix", Op: "prefix", Value: " padded "}},
+		{expr: "Repo.Kind equals gitlab", want: condition{Field: "Repo.Kind", Op: "equals", Value: "gitlab"}},
+		{expr: "Suffix", wantErr: true},
+		{expr: "Suffix matches x", wantErr: true},
+		{expr: "Suffix equals", wantErr: true},
+		{expr: "Suffix set now", wantErr: true},
+		{expr: `Suffix equals "unterminated`, wantErr: true},
+		{expr: "Nope set", wantErr: true},
+		{expr: "Repo.Nope set", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseCondition(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCondition(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCondition(%q) = %+v, want %+v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchVariantRule(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		version string
+		config  Config
+		want    string
+	}{
+		{
+			name:    "domain and GitLab repository",
+			version: "v2",
+			config:  Config{FullDomainName: "web.example.com", GitLabRepoURL: "https://gitlab.example.com/grp/proj.git"},
+			want:    "git-gate",
+		},
+		{
+			name:    "domain and GitHub repository",
+			version: "v2",
+			config:  Config{FullDomainName: "web.example.com", GitLabRepoURL: "https://github.com/org/proj.git"},
+			want:    "gateway",
+		},
+		{
+			name:    "GitLab repository only",
+			version: "v2",
+			config:  Config{GitLabRepoURL: "git@gitlab.example.com:grp/proj.git"},
+			want:    "gitrepo",
+		},
+		{
+			name:    "onboarding test suffix",
+			version: "v2",
+			config:  Config{Suffix: "ob-test-1"},
+			want:    "apptest",
+		},
+		{
+			name:    "nothing set",
+			version: "v2",
+			config:  Config{Suffix: "web"},
+			want:    "default",
+		},
+		{
+			name:    "legacy set, domain and GitLab repository",
+			version: legacyTemplateVersion,
+			config:  Config{FullDomainName: "web.example.com", GitLabRepoURL: "https://gitlab.example.com/grp/proj.git"},
+			want:    "gateway",
+		},
+		{
+			name:    "legacy set, GitLab repository only",
+			version: legacyTemplateVersion,
+			config:  Config{GitLabRepoURL: "git@gitlab.example.com:grp/proj.git"},
+			want:    "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := g.matchVariantRule(&tt.config, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rule.Name != tt.want {
+				t.Errorf("matchVariantRule() = %s, want %s", rule.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchVariantRuleUsesRepoHostKinds(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir(), RepoHostKinds: map[string]string{"Git.Internal.example.com": repoKindGitLab}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{GitLabRepoURL: "https://git.internal.example.com/grp/proj.git"}
+	rule, err := g.matchVariantRule(config, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Name != "gitrepo" {
+		t.Errorf("matchVariantRule() = %s, want gitrepo", rule.Name)
+	}
+
+	if _, err := New(Options{OutputRoot: t.TempDir(), RepoHostKinds: map[string]string{"git.internal.example.com": "gitea"}}); err == nil {
+		t.Error("New() accepted an unknown repository kind")
+	}
+}
@@ -0,0 +1,253 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultTemplates holds the template sets built into the binary, one
+// directory per version, so createFiles works outside a checkout.
+//
+//go:embed templates/*/*.yaml
+var defaultTemplates embed.FS
+
+const defaultTemplateRoot = "templates"
+
+// templateOriginEmbedded marks a template taken from the binary.
+const templateOriginEmbedded = "embedded"
+
+// legacyTemplateVersion is the set tenants generated before template sets
+// were versioned are pinned to. Files directly in TemplateDir predate
+// versioning too and override this set.
+const legacyTemplateVersion = "v1"
+
+// templateVersionName is the form of a template set directory name.
+var templateVersionName = regexp.MustCompile(`^v[0-9]+(\.[0-9]+)*$`)
+
+// templateSource is one template of the effective set.
+type templateSource struct {
+	Name string
+	// Origin is templateOriginEmbedded or the path of the override file.
+	Origin string
+	// Shadows is set when an override replaces an embedded template.
+	Shadows bool
+
+	data []byte
+}
+
+// compareTemplateVersions orders versions numerically, so v10 sorts after v9.
+func compareTemplateVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// templateVersions lists every template set, embedded or only present as a
+// version directory in TemplateDir, oldest first.
+func (g *Generator) templateVersions() ([]string, error) {
+	seen := make(map[string]bool)
+	entries, err := fs.ReadDir(defaultTemplates, defaultTemplateRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded template sets: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && templateVersionName.MatchString(entry.Name()) {
+			seen[entry.Name()] = true
+		}
+	}
+
+	if g.opts.TemplateDir != "" {
+		entries, err = os.ReadDir(g.opts.TemplateDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %v", g.opts.TemplateDir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && templateVersionName.MatchString(entry.Name()) {
+				seen[entry.Name()] = true
+			}
+		}
+	}
+
+	versions := make([]string, 0, len(seen))
+	for version := range seen {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareTemplateVersions(versions[i], versions[j]) < 0 })
+	return versions, nil
+}
+
+// resolveTemplateVersion checks that version exists, with "" meaning the
+// default set.
+func (g *Generator) resolveTemplateVersion(version string) (string, error) {
+	versions, err := g.templateVersions()
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no template sets available")
+	}
+	if version == "" {
+		version = g.opts.TemplateVersion
+	}
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if v == version {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("unknown template set %s, available: %s", version, strings.Join(versions, ", "))
+}
+
+// pinnedTemplateVersion returns the set a tenant is generated from: the one
+// recorded in its directory, legacyTemplateVersion for an existing directory
+// without a record, or the default set for a new tenant.
+func (g *Generator) pinnedTemplateVersion(config *Config) (string, error) {
+	dir := g.tenantDir(config)
+	if _, err := os.Stat(filepath.Join(dir, tenantRecordFile)); os.IsNotExist(err) {
+		if _, err := os.Stat(dir); err == nil {
+			return g.resolveTemplateVersion(legacyTemplateVersion)
+		}
+		return g.resolveTemplateVersion("")
+	}
+	record, err := readTenantRecord(dir)
+	if err != nil {
+		return "", err
+	}
+	version, err := g.resolveTemplateVersion(record.templateVersion())
+	if err != nil {
+		return "", fmt.Errorf("%s is pinned to a template set that is not available: %v", dir, err)
+	}
+	return version, nil
+}
+
+// templateFS returns the overlay a template set is read from: the embedded
+// set, then for the legacy set the files directly in TemplateDir, then
+// TemplateDir/<version>. A file in an override directory replaces the
+// embedded template of the same name or adds a new one.
+func (g *Generator) templateFS(version string) (*templateOverlay, error) {
+	embedded, err := fs.Sub(defaultTemplates, path.Join(defaultTemplateRoot, version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded template set %s: %v", version, err)
+	}
+	overlay := &templateOverlay{layers: []templateLayer{{fsys: embedded}}}
+	if g.opts.TemplateDir == "" {
+		return overlay, nil
+	}
+
+	overrideDirs := []string{filepath.Join(g.opts.TemplateDir, version)}
+	if version == legacyTemplateVersion {
+		overrideDirs = append([]string{g.opts.TemplateDir}, overrideDirs...)
+	}
+	for _, dir := range overrideDirs {
+		overlay.layers = append(overlay.layers, templateLayer{fsys: os.DirFS(dir), dir: dir})
+	}
+	return overlay, nil
+}
+
+// effectiveTemplates lists the *.yaml files of a template set's overlay with
+// where each came from.
+func (g *Generator) effectiveTemplates(version string) ([]templateSource, error) {
+	overlay, err := g.templateFS(version)
+	if err != nil {
+		return nil, err
+	}
+	names, err := fs.Glob(overlay, "*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template set %s: %v", version, err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("template set %s has no templates", version)
+	}
+
+	sources := make([]templateSource, 0, len(names))
+	for _, name := range names {
+		layer, err := overlay.layerOf(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %v", name, err)
+		}
+		data, err := fs.ReadFile(layer.fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %v", name, err)
+		}
+		src := templateSource{Name: name, Origin: templateOriginEmbedded, data: data}
+		if layer.dir != "" {
+			src.Origin = filepath.Join(layer.dir, name)
+			_, err := fs.Stat(overlay.layers[0].fsys, name)
+			src.Shadows = err == nil
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// fingerprintTemplates hashes a template set by name and content.
+func fingerprintTemplates(sources []templateSource) string {
+	hash := sha256.New()
+	for _, src := range sources {
+		fmt.Fprintf(hash, "%s\x00%d\x00", src.Name, len(src.data))
+		hash.Write(src.data)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// printTemplates writes one line per template in effect with its origin.
+func printTemplates(w io.Writer, version string, sources []templateSource) {
+	overrides := 0
+	for _, src := range sources {
+		origin := src.Origin
+		if src.Shadows {
+			origin += " (overrides embedded)"
+		}
+		if src.Origin != templateOriginEmbedded {
+			overrides++
+		}
+		fmt.Fprintf(w, "%-32s %s\n", src.Name, origin)
+	}
+	fmt.Fprintf(w, "Template set %s: %d in effect, %d overridden or added, fingerprint %s\n", version, len(sources), overrides, fingerprintTemplates(sources))
+}
+
+// ListTemplates writes which templates of a set are in effect to w, and
+// where each came from. An empty version lists the default set.
+func (g *Generator) ListTemplates(w io.Writer, version string) error {
+	version, err := g.resolveTemplateVersion(version)
+	if err != nil {
+		return err
+	}
+	sources, err := g.effectiveTemplates(version)
+	if err != nil {
+		return err
+	}
+	versions, _ := g.templateVersions()
+	defaultVersion, _ := g.resolveTemplateVersion("")
+	fmt.Fprintf(w, "Template sets: %s (default %s)\n", strings.Join(versions, ", "), defaultVersion)
+	printTemplates(w, version, sources)
+	return nil
+}
@@ -0,0 +1,26 @@
+package generator
+
+import "testing"
+
+// TestReleasedTemplateSetsAreFrozen guards the embedded sets tenants are
+// pinned to. Change a released set by adding a new version instead.
+func TestReleasedTemplateSetsAreFrozen(t *testing.T) {
+	released := map[string]string{
+		"v1": "sha256:46c4004a48bb",
+		"v2": "sha256:d109884e6373",
+		"v3": "sha256:3e16c4e50787",
+	}
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for version, want := range released {
+		sources, err := g.effectiveTemplates(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fingerprintTemplates(sources); got != want {
+			t.Errorf("template set %s fingerprint = %s, want %s", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// defaultEnvironmentDirs maps operational environments onto the directory they
+// are stored under when Options leaves EnvironmentDirs nil. Test tenants share
+// the dev tree.
+var defaultEnvironmentDirs = map[string]string{"test": "dev"}
+
+// envDirFor maps an operational environment onto the directory it is stored
+// under. Environments not in EnvironmentDirs use their own name.
+func (g *Generator) envDirFor(opEnvironment string) string {
+	if dir, ok := g.opts.EnvironmentDirs[strings.ToLower(opEnvironment)]; ok {
+		return dir
+	}
+	return opEnvironment
+}
+
+// tenantNamespace returns the <swci>-<env>-<suffix> name used for both the
+// tenant namespace and its directory.
+func tenantNamespace(config *Config) string {
+	return fmt.Sprintf("%s-%s-%s", config.Swci, config.OpEnvironment, config.Suffix)
+}
+
+// clusterDirFor returns the cluster directory that holds the tenant directories.
+func (g *Generator) clusterDirFor(config *Config) string {
+	return filepath.Join(g.opts.OutputRoot, g.envDirFor(config.OpEnvironment), config.Region, config.ClusterName)
+}
+
+// tenantDir returns the directory a tenant is generated into.
+func (g *Generator) tenantDir(config *Config) string {
+	return filepath.Join(g.clusterDirFor(config), tenantNamespace(config))
+}
+
+// templateFile is one overlay template, by its name in the template set, and
+// the file name it renders to in the tenant directory.
+type templateFile struct {
+	Source string
+	Dest   string
+}
+
+// AddOrModify generates the tenant described by config, or re-renders it on
+// the template set it is pinned to when it already exists.
+func (g *Generator) AddOrModify(config *Config) error {
+	// Reject bad input before any path is built from it
+	if err := g.checkConfig(config); err != nil {
+		return err
+	}
+
+	// Existing tenants stay on the template set they are pinned to
+	version, err := g.pinnedTemplateVersion(config)
+	if err != nil {
+		return err
+	}
+	return g.generateTenant(config, version)
+}
+
+// generateTenant renders a validated tenant from a template set and pins it
+// to that set.
+func (g *Generator) generateTenant(config *Config, version string) error {
+	// Construct the target directory path
+	dir := g.tenantDir(config)
+	g.logger.Printf("Target directory: %s (template set %s)", dir, version)
+
+	if repo := repoSourceFor(config, g.opts.RepoHostKinds); repo.Kind != "" {
+		g.logger.Printf("Repository source: %s %s, group %s, project %s, branch %q", repo.Kind, repo.Host, repo.Group, repo.Project, repo.Branch)
+	}
+
+	variant, files, err := g.selectTemplateFiles(config, version)
+	if err != nil {
+		return err
+	}
+	delivery, err := g.gitOpsFlavourFor(config)
+	if err != nil {
+		return err
+	}
+
+	// Render into a staging copy of the target directory so a failure
+	// part-way through never leaves a half-populated tenant behind
+	stage, err := g.stageTenantDir(dir)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+	g.logger.Printf("Created staging directory %s", stage)
+
+	// Log all configuration values for debugging
+	g.logger.Printf("Config values:")
+	v := reflect.ValueOf(*config)
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		g.logger.Printf("%s: %v", t.Field(i).Name, v.Field(i).Interface())
+	}
+
+	for _, file := range files {
+		g.logger.Printf("Processing %s -> %s", file.Source, file.Dest)
+		data, err := g.renderTemplateFile(version, file.Source, config)
+		if err != nil {
+			return fmt.Errorf("failed to process %s: %v", file.Source, err)
+		}
+		if err := os.WriteFile(filepath.Join(stage, file.Dest), data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", file.Dest, err)
+		}
+	}
+
+	// Drop files from an earlier rendering that this Config no longer produces
+	if err := g.pruneOrphans(stage, files); err != nil {
+		return err
+	}
+
+	// Hostnames and gateways must be ones the cluster allows the tenant
+	rendered, err := readTenantFiles(stage)
+	if err != nil {
+		return err
+	}
+	if err := g.checkRouting(config, rendered); err != nil {
+		return err
+	}
+
+	// Reserve the tenant's hostnames and cluster slot until it is in place
+	release, err := g.reserveHostnames(config, stage)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Record the inputs so the tenant can be regenerated later
+	record := g.newTenantRecord(config, version, variant)
+	record.GitOps = gitOpsFlavourName(delivery)
+	if err := record.hashRenderedFiles(stage, files); err != nil {
+		return err
+	}
+	// An unchanged tenant keeps its record, so a re-run leaves no diff
+	previous, err := readPreviousRecord(stage)
+	if err != nil {
+		return err
+	}
+	if record.sameGeneration(previous) {
+		record = previous
+	}
+	if err := writeTenantRecord(stage, record); err != nil {
+		return err
+	}
+
+	// Make sure the result builds before GitOps tries to apply it
+	if err := g.verifyTenantBuild(config, stage); err != nil {
+		return err
+	}
+
+	// Render the delivery objects now, so nothing is swapped in when they fail
+	deliveryObjects, err := g.renderGitOpsObjects(config, delivery)
+	if err != nil {
+		return err
+	}
+
+	// Every file succeeded, swap the staging directory into place
+	if err := g.swapTenantDir(stage, dir); err != nil {
+		return err
+	}
+	g.logger.Printf("Updated target directory %s", dir)
+
+	// List the tenant in the cluster kustomization
+	clusterDir := g.clusterDirFor(config)
+	changed, err := g.addKustomizationResource(clusterDir, dir)
+	if err != nil {
+		return fmt.Errorf("failed to register tenant in %s: %v", clusterDir, err)
+	}
+	if changed {
+		g.logger.Printf("Registered %s in %s", tenantNamespace(config), findKustomizationFile(clusterDir))
+	}
+
+	// Publish the tenant's hostnames to the cluster's ownership map
+	g.hostnameClaimsMu.Lock()
+	changed, err = g.writeHostnameOwnership(clusterDir)
+	g.hostnameClaimsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if changed {
+		g.logger.Printf("Updated hostname ownership in %s", filepath.Join(clusterDir, hostnameOwnershipFile))
+	}
+
+	// Point the cluster's delivery tool at the tenant directory
+	if err := g.writeGitOpsObjects(config, delivery, deliveryObjects); err != nil {
+		return err
+	}
+	g.logger.Printf("GitOps delivery: %s", gitOpsFlavourName(delivery))
+
+	// Final check
+	kustomizations, _ := filepath.Glob(filepath.Join(dir, "kustomization*.yaml"))
+	g.logger.Printf("Number of kustomization files in target directory: %d", len(kustomizations))
+	for _, file := range kustomizations {
+		g.logger.Printf("Kustomization file in target directory: %s", filepath.Base(file))
+	}
+
+	return nil
+}
+
+// selectTemplateFiles decides which overlay templates of a template set a
+// tenant gets: the kustomization variant chosen by the variant rules plus every
+// other template whose condition holds.
+func (g *Generator) selectTemplateFiles(config *Config, version string) (*variantRule, []templateFile, error) {
+	// Pick the kustomization variant - only create one
+	rule, err := g.matchVariantRule(config, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Embedded templates merged with the overrides in TemplateDir
+	templates, err := g.templateFS(version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected := []templateFile{{
+		Source: rule.Source,
+		Dest:   "kustomization.yaml",
+	}}
+
+	// Select other files
+	files, err := fs.Glob(templates, "*.yaml")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to glob files: %v", err)
+	}
+	g.logger.Printf("Found %d YAML files in the template set", len(files))
+
+	repo := repoSourceFor(config, g.opts.RepoHostKinds)
+	for _, baseFileName := range files {
+
+		// Skip the rules file itself
+		if baseFileName == variantRulesFile {
+			continue
+		}
+
+		// Skip all kustomization files
+		variantSource, err := g.isVariantSource(version, baseFileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.HasPrefix(baseFileName, "kustomization") || variantSource {
+			g.logger.Printf("Skipping kustomization file: %s", baseFileName)
+			continue
+		}
+
+		// Each template declares its own include conditions
+		include, reason, err := includeTemplate(templates, baseFileName, config, repo)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !include {
+			g.logger.Printf("Skipping %s (%s)", baseFileName, reason)
+			continue
+		}
+		g.logger.Printf("Including %s (%s)", baseFileName, reason)
+
+		selected = append(selected, templateFile{Source: baseFileName, Dest: baseFileName})
+	}
+
+	return rule, selected, nil
+}
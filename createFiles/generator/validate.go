@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// allowedEnvironments and allowedRegions bound where tenants may be created.
+// A cluster catalog replaces allowedRegions with the regions of its clusters.
+var (
+	allowedEnvironments = []string{"dev", "test", "preprod", "prod"}
+	allowedRegions      = []string{"uksouth", "ukwest", "northeurope", "westeurope"}
+)
+
+// Validation error codes, the Code of a ValidationError.
+const (
+	CodeRequired   = "required"
+	CodeInvalid    = "invalid"
+	CodeTooLong    = "too_long"
+	CodeNotAllowed = "not_allowed"
+)
+
+var dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+const (
+	maxLabelLength    = 63
+	maxHostnameLength = 253
+)
+
+// ValidationError is a single problem with one Config field.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every problem found in a Config so callers can
+// report them all at once. AddOrModify, Apply, Plan, WritePlan and Delete
+// return it as is when they reject a Config.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+	}
+	return fmt.Sprintf("invalid config: %s", strings.Join(msgs, "; "))
+}
+
+// JSON renders the errors for pipelines that parse the output.
+func (e ValidationErrors) JSON() string {
+	data, _ := json.MarshalIndent(e, "", "  ")
+	return string(data)
+}
+
+func (e *ValidationErrors) add(field, value, code, format string, args ...interface{}) {
+	*e = append(*e, ValidationError{Field: field, Value: value, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// validateConfig checks every field AddOrModify builds paths and
+// resources from, accepting the given regions and classifying the repository
+// host with hostKinds. It returns nil or a ValidationErrors listing all
+// problems.
+func validateConfig(config *Config, regions []string, hostKinds map[string]string) error {
+	var errs ValidationErrors
+
+	checkAllowed(&errs, "OpEnvironment", config.OpEnvironment, allowedEnvironments)
+	checkAllowed(&errs, "Region", config.Region, regions)
+	checkLabel(&errs, "ClusterName", config.ClusterName)
+	checkLabel(&errs, "Swci", config.Swci)
+	checkLabel(&errs, "Suffix", config.Suffix)
+
+	checkNamespace(&errs, config)
+
+	if config.FullDomainName != "" {
+		if msg := hostnameProblem(config.FullDomainName); msg != "" {
+			errs.add("FullDomainName", config.FullDomainName, CodeInvalid, "%s", msg)
+		}
+	}
+	if config.GitLabRepoURL != "" {
+		if _, err := parseRepoSource(config.GitLabRepoURL, hostKinds); err != nil {
+			errs.add("GitLabRepoURL", config.GitLabRepoURL, CodeInvalid, "%v", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateLocation checks only the fields that locate a tenant directory, so
+// Delete can remove a tenant whose cluster has left the catalog or whose other
+// fields no longer validate.
+func validateLocation(config *Config) error {
+	var errs ValidationErrors
+
+	checkLabel(&errs, "OpEnvironment", config.OpEnvironment)
+	checkLabel(&errs, "Region", config.Region)
+	checkLabel(&errs, "ClusterName", config.ClusterName)
+	checkLabel(&errs, "Swci", config.Swci)
+	checkLabel(&errs, "Suffix", config.Suffix)
+	checkNamespace(&errs, config)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkNamespace checks the combined name, which is both the namespace and
+// the directory name.
+func checkNamespace(errs *ValidationErrors, config *Config) {
+	if config.Swci == "" || config.OpEnvironment == "" || config.Suffix == "" {
+		return
+	}
+	name := tenantNamespace(config)
+	if len(name) > maxLabelLength {
+		errs.add("Namespace", name, CodeTooLong, "must be at most %d characters, got %d", maxLabelLength, len(name))
+	} else if !dns1123Label.MatchString(name) {
+		errs.add("Namespace", name, CodeInvalid, "must be a DNS-1123 label")
+	}
+}
+
+func checkAllowed(errs *ValidationErrors, field, value string, allowed []string) {
+	if value == "" {
+		errs.add(field, value, CodeRequired, "is required")
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	errs.add(field, value, CodeNotAllowed, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+func checkLabel(errs *ValidationErrors, field, value string) {
+	switch {
+	case value == "":
+		errs.add(field, value, CodeRequired, "is required")
+	case len(value) > maxLabelLength:
+		errs.add(field, value, CodeTooLong, "must be at most %d characters", maxLabelLength)
+	case !dns1123Label.MatchString(value):
+		errs.add(field, value, CodeInvalid, "must be a DNS-1123 label (lowercase alphanumerics and '-')")
+	}
+}
+
+// hostnameProblem describes why host is not a valid fully qualified hostname,
+// or returns an empty string if it is.
+func hostnameProblem(host string) string {
+	if len(host) > maxHostnameLength {
+		return fmt.Sprintf("must be at most %d characters", maxHostnameLength)
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "must be a fully qualified hostname"
+	}
+	for _, label := range labels {
+		if len(label) > maxLabelLength || !dns1123Label.MatchString(label) {
+			return fmt.Sprintf("label %q is not a valid DNS label", label)
+		}
+	}
+	return ""
+}
+
+// checkConfig validates config before anything is written and logs the error
+// list as JSON so pipelines can pick it up.
+func (g *Generator) checkConfig(config *Config) error {
+	return g.logValidation(g.validateTarget(config))
+}
+
+// checkLocation is checkConfig for Delete: it validates only the fields that
+// locate the tenant directory.
+func (g *Generator) checkLocation(config *Config) error {
+	return g.logValidation(validateLocation(config))
+}
+
+// logValidation logs a ValidationErrors as JSON and returns err unchanged.
+func (g *Generator) logValidation(err error) error {
+	if errs, ok := err.(ValidationErrors); ok {
+		g.logger.Printf("Config validation failed:\n%s", errs.JSON())
+	}
+	return err
+}
+
+// validateTarget runs validateConfig and then checks the target cluster
+// against the cluster catalog.
+func (g *Generator) validateTarget(config *Config) error {
+	regions, err := g.allowedTenantRegions()
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(config, regions, g.opts.RepoHostKinds); err != nil {
+		return err
+	}
+	// Only a well-formed target can be looked up in the catalog
+	var errs ValidationErrors
+	if err := g.checkCatalogTarget(&errs, config); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
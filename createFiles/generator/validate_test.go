@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// errorFields lists the field and code of every problem in err, or nil.
+func errorFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error %v is not ValidationErrors", err)
+	}
+	fields := make([]string, len(errs))
+	for i, fe := range errs {
+		fields[i] = fe.Field + ":" + fe.Code
+	}
+	return fields
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid := Config{
+		OpEnvironment:  "dev",
+		Region:         "uksouth",
+		ClusterName:    "aks1",
+		Swci:           "ab12",
+		Suffix:         "web",
+		FullDomainName: "web.apps.example.com",
+		GitLabRepoURL:  "https://gitlab.com/team/web.git",
+	}
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "optional fields empty", modify: func(c *Config) { c.FullDomainName, c.GitLabRepoURL = "", "" }},
+		{
+			name:   "every problem is reported",
+			modify: func(c *Config) { *c = Config{Region: "mars", ClusterName: "AKS_1", Suffix: "web"} },
+			want:   []string{"OpEnvironment:required", "Region:not_allowed", "ClusterName:invalid", "Swci:required"},
+		},
+		{
+			name:   "field and namespace problems together",
+			modify: func(c *Config) { c.OpEnvironment = "staging"; c.Suffix = strings.Repeat("a", 64) },
+			want:   []string{"OpEnvironment:not_allowed", "Suffix:too_long", "Namespace:too_long"},
+		},
+		{name: "unqualified domain", modify: func(c *Config) { c.FullDomainName = "web" }, want: []string{"FullDomainName:invalid"}},
+		{name: "bad domain label", modify: func(c *Config) { c.FullDomainName = "web_1.example.com" }, want: []string{"FullDomainName:invalid"}},
+		{name: "bad repository", modify: func(c *Config) { c.GitLabRepoURL = "not a url" }, want: []string{"GitLabRepoURL:invalid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			got := errorFields(t, validateConfig(&config, allowedRegions, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateConfig() problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNamespace(t *testing.T) {
+	tests := []struct {
+		name   string
+		suffix string
+		want   []string
+	}{
+		// ab12-dev- is 9 characters
+		{name: "at the limit", suffix: strings.Repeat("a", maxLabelLength-9)},
+		{name: "one over the limit", suffix: strings.Repeat("a", maxLabelLength-8), want: []string{"Namespace:too_long"}},
+		{name: "trailing hyphen", suffix: "web-", want: []string{"Namespace:invalid"}},
+		{name: "missing part is left to the field checks", suffix: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs ValidationErrors
+			checkNamespace(&errs, &Config{OpEnvironment: "dev", Swci: "ab12", Suffix: tt.suffix})
+			var err error
+			if len(errs) > 0 {
+				err = errs
+			}
+			if got := errorFields(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkNamespace() problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddOrModifyReturnsValidationErrors(t *testing.T) {
+	g, err := New(Options{OutputRoot: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.AddOrModify(&Config{OpEnvironment: "dev", Region: "mars", ClusterName: "aks1", Swci: "ab12"})
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("AddOrModify() error = %v, want ValidationErrors", err)
+	}
+	got := make(map[string]string)
+	for _, fe := range errs {
+		got[fe.Field] = fe.Code
+	}
+	want := map[string]string{"Region": CodeNotAllowed, "Suffix": CodeRequired}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("%s code = %q, want %q (all errors: %v)", field, got[field], code, errs)
+		}
+	}
+}